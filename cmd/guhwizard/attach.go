@@ -0,0 +1,102 @@
+// FILE: cmd/guhwizard/attach.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"guhwizard/internal/control"
+)
+
+// runAttach talks to a running install over its control socket.
+//
+//	guhwizard attach            status, then follow the log
+//	guhwizard attach status
+//	guhwizard attach logs
+//	guhwizard attach cancel
+//	guhwizard attach answer ID TEXT
+func runAttach(args []string) int {
+	fset := flag.NewFlagSet("attach", flag.ExitOnError)
+	socket := fset.String("socket", "", "Path to the control socket (default $XDG_RUNTIME_DIR/"+control.SocketName+")")
+	fset.Parse(args)
+
+	path := *socket
+	if path == "" {
+		p, err := control.SocketPath()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot locate control socket: %v\n", err)
+			return 1
+		}
+		path = p
+	}
+
+	cmd := "follow"
+	rest := fset.Args()
+	if len(rest) > 0 {
+		cmd, rest = rest[0], rest[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "follow":
+		if err = printStatus(path); err == nil {
+			err = control.StreamLogs(path, func(line string) { fmt.Println(line) })
+		}
+	case "status":
+		err = printStatus(path)
+	case "logs":
+		err = control.StreamLogs(path, func(line string) { fmt.Println(line) })
+	case "cancel":
+		if _, err = control.Send(path, control.Request{Cmd: "cancel"}); err == nil {
+			fmt.Println("Cancellation requested.")
+		}
+	case "answer":
+		if len(rest) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: guhwizard attach answer ID TEXT")
+			return 2
+		}
+		id, convErr := strconv.Atoi(rest[0])
+		if convErr != nil {
+			fmt.Fprintf(os.Stderr, "Invalid prompt id %q\n", rest[0])
+			return 2
+		}
+		_, err = control.Send(path, control.Request{Cmd: "answer", ID: id, Answer: strings.Join(rest[1:], " ")})
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown attach command %q (status, logs, cancel, answer)\n", cmd)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func printStatus(path string) error {
+	resp, err := control.Send(path, control.Request{Cmd: "status"})
+	if err != nil {
+		return err
+	}
+
+	st := resp.Status
+	state := "running"
+	if st.Cancelled {
+		state = "cancelling"
+	} else if !st.Running {
+		state = "finished"
+	}
+	fmt.Printf("[%s] %3.0f%% %s\n", state, st.Percent*100, st.Step)
+
+	for _, p := range st.Prompts {
+		fmt.Printf("Prompt %d: %s\n", p.ID, p.Question)
+		for i, c := range p.Choices {
+			fmt.Printf("  %d) %s\n", i+1, c)
+		}
+		fmt.Printf("  answer with: guhwizard attach answer %d <text>\n", p.ID)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// FILE: cmd/guhwizard/audit.go
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"guhwizard/internal/audit"
+)
+
+// runAudit shows the privileged operations recorded in the audit log.
+//
+//	guhwizard audit [--last N] [--failed] [--json]
+func runAudit(args []string) int {
+	fset := flag.NewFlagSet("audit", flag.ExitOnError)
+	last := fset.Int("last", 0, "Only show the last N entries")
+	failed := fset.Bool("failed", false, "Only show operations that failed or were refused")
+	asJSON := fset.Bool("json", false, "Print the entries as JSON lines")
+	fset.Parse(args)
+
+	entries, err := audit.Read()
+	if os.IsNotExist(err) {
+		fmt.Printf("No privileged operations recorded yet (%s).\n", audit.File)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read the audit log: %v\n", err)
+		return 1
+	}
+
+	if *failed {
+		var kept []audit.Entry
+		for _, e := range entries {
+			if e.ExitCode != 0 {
+				kept = append(kept, e)
+			}
+		}
+		entries = kept
+	}
+	if *last > 0 && len(entries) > *last {
+		entries = entries[len(entries)-*last:]
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range entries {
+		if *asJSON {
+			enc.Encode(e)
+			continue
+		}
+		printAuditEntry(e)
+	}
+	return 0
+}
+
+func printAuditEntry(e audit.Entry) {
+	status := "ok"
+	if e.ExitCode != 0 {
+		status = fmt.Sprintf("exit %d", e.ExitCode)
+	}
+	fmt.Printf("%s  %-8s %-6s %s via %s", e.Start.Local().Format(time.DateTime), status, e.End.Sub(e.Start).Round(time.Millisecond), e.User, e.Via)
+	if e.Cause != "" {
+		fmt.Printf("  [%s]", e.Cause)
+	}
+	fmt.Printf("\n    %s: %s\n", e.Op, strings.Join(e.Argv, " "))
+	if e.Dir != "" {
+		fmt.Printf("    in %s\n", e.Dir)
+	}
+	if e.Error != "" && e.ExitCode != 0 {
+		fmt.Printf("    %s\n", e.Error)
+	}
+}
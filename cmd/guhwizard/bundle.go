@@ -0,0 +1,50 @@
+// FILE: cmd/guhwizard/bundle.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/installer"
+)
+
+// runBundle prepares an offline bundle.
+//
+//	guhwizard bundle [--escalation NAME] [--without-helper] [--config FILE] DIR
+func runBundle(args []string) int {
+	fset := flag.NewFlagSet("bundle", flag.ExitOnError)
+	blueprint := fset.String("config", defaultBlueprint, "Installation blueprint")
+	escalation := fset.String("escalation", "", "Privilege escalation backend: sudo, doas or run0 (default: blueprint, then detected)")
+	fset.BoolVar(&installer.DirectEscalation, "without-helper", false, "Call the escalation backend for every command instead of starting the privileged helper")
+	fset.Parse(args)
+
+	if fset.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: guhwizard bundle [--escalation NAME] [--without-helper] [--config FILE] DIR")
+		return 2
+	}
+
+	cfg, err := config.Load(*blueprint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", *blueprint, err)
+		return 1
+	}
+
+	if err := selectBackend(*escalation, cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := startPrivilegedHelper(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer installer.StopPrivilegedHelper()
+
+	if err := installer.CreateBundle(cfg, fset.Arg(0), func(s string) { fmt.Print(s) }); err != nil {
+		fmt.Fprintf(os.Stderr, "Bundle failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
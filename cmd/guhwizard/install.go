@@ -0,0 +1,130 @@
+// FILE: cmd/guhwizard/install.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/escalate"
+	"guhwizard/internal/installer"
+	"guhwizard/internal/target"
+	"guhwizard/internal/ui"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// installOptions are the flags of install, shared with run.
+type installOptions struct {
+	offlineDir string
+	locked     bool
+	lockfile   string
+	blueprint  string
+	escalation string
+	targetUser string
+}
+
+func parseInstallFlags(name string, args []string) *installOptions {
+	opts := &installOptions{}
+	fset := flag.NewFlagSet(name, flag.ExitOnError)
+	fset.StringVar(&opts.offlineDir, "offline", "", "Install from a bundle created by 'guhwizard bundle'")
+	fset.BoolVar(&opts.locked, "locked", false, "Install the versions recorded in the lockfile where possible")
+	fset.StringVar(&opts.lockfile, "lockfile", installer.LockfileName, "Lockfile used by --locked")
+	fset.StringVar(&opts.blueprint, "config", defaultBlueprint, "Installation blueprint")
+	fset.StringVar(&opts.escalation, "escalation", "", "Privilege escalation backend: sudo, doas or run0 (default: blueprint, then detected)")
+	fset.StringVar(&opts.targetUser, "target-user", "", "Install for this user instead of the one running guhwizard (needs root, their home is only written as them)")
+	fset.BoolVar(&installer.DirectEscalation, "without-helper", false, "Call the escalation backend for every command instead of starting the privileged helper")
+	fset.Parse(args)
+	return opts
+}
+
+// runInstall launches the TUI installer.
+//
+//	guhwizard install [--offline DIR] [--locked [--lockfile FILE]] [--escalation NAME] [--without-helper] [--target-user NAME] [--config FILE]
+func runInstall(args []string) int {
+	return install(parseInstallFlags("install", args))
+}
+
+func install(opts *installOptions) int {
+	// Also after a failure or ctrl+c, p.Run returns and these still run.
+	// The sudoers rule goes first, while the credentials are still cached.
+	defer installer.StopPrivilegedHelper()
+	defer installer.CurrentSession.StopSudo()
+	defer removeSudoersRule()
+	removeSudoersRuleOnSignal()
+
+	// Dotfiles, the shell and AUR builds are for the user, also under sudo
+	if err := target.Init(opts.targetUser); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// 1. Load the Installation Blueprint
+	// In a real release, you might embed this file into the binary using `//go:embed`
+	// so you don't need the external file at runtime.
+	cfg, err := config.Load(opts.blueprint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		fmt.Printf("Make sure '%s' is in the current directory.\n", opts.blueprint)
+		return 1
+	}
+	// The same checks as lint, before anything is installed
+	if errs := config.Validate(cfg); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", opts.blueprint, err)
+		}
+		fmt.Fprintf(os.Stderr, "Fix the blueprint (see 'guhwizard lint %s') and try again.\n", opts.blueprint)
+		return 1
+	}
+
+	if err := selectBackend(opts.escalation, cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	enforceSudoersExpiry()
+
+	if opts.offlineDir != "" {
+		disable, err := installer.EnableOffline(opts.offlineDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot use offline bundle: %v\n", err)
+			return 1
+		}
+		defer disable()
+	}
+
+	if opts.locked {
+		if err := installer.UseLockfile(opts.lockfile); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot use lockfile: %v\n", err)
+			return 1
+		}
+	}
+
+	// Without cached credentials the TUI asks for the password itself and
+	// starts the helper afterwards, sudo can't prompt behind the alt screen.
+	// doas and run0 only ask on the terminal, so that has to happen now.
+	if installer.ValidateSudo() == nil || !escalate.Current().AcceptsPassword() {
+		if err := startPrivilegedHelper(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	// However the credentials got cached, they have to outlast sudo's timeout
+	// for yay and paru. The TUI's password prompt starts this itself.
+	if installer.ValidateSudo() == nil {
+		installer.CurrentSession.KeepAlive()
+	}
+
+	// 2. Initialize the UI Model with the Config
+	model := ui.NewModel(cfg)
+
+	// 3. Run the Bubble Tea Program
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	setOnSignal(p.Kill)
+	defer setOnSignal(nil)
+	if _, err := p.Run(); err != nil {
+		fmt.Printf("Alas, there's been an error: %v", err)
+		return 1
+	}
+	return 0
+}
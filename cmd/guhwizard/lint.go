@@ -0,0 +1,68 @@
+// FILE: cmd/guhwizard/lint.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/installer"
+)
+
+// runLint checks a blueprint for CI.
+//
+//	guhwizard lint [--check-packages] [--db DIR] [blueprint.yaml]
+func runLint(args []string) int {
+	fset := flag.NewFlagSet("lint", flag.ExitOnError)
+	checkPackages := fset.Bool("check-packages", false, "Resolve every package against the repos and the AUR")
+	dbDir := fset.String("db", "", "Use a local snapshot (sync *.db files + AUR packages.gz) instead of the live system")
+	fset.Parse(args)
+
+	path := defaultBlueprint
+	if fset.NArg() > 0 {
+		path = fset.Arg(0)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", path, err)
+		return 1
+	}
+
+	problems := 0
+	for _, err := range config.Validate(cfg) {
+		fmt.Printf("%s: %v\n", path, err)
+		problems++
+	}
+
+	if *checkPackages {
+		var idx *installer.PackageIndex
+		if *dbDir != "" {
+			idx, err = installer.SnapshotIndex(*dbDir)
+		} else {
+			idx, err = installer.OnlineIndex()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot check packages: %v\n", err)
+			return 1
+		}
+
+		missing, err := idx.Unresolved(installer.BlueprintPackages(cfg))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot check packages: %v\n", err)
+			return 1
+		}
+		for _, name := range missing {
+			fmt.Printf("%s: package %q not found in the repos or the AUR\n", path, name)
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		fmt.Printf("%d problem(s) found.\n", problems)
+		return 1
+	}
+	fmt.Println("OK")
+	return 0
+}
@@ -6,42 +6,164 @@ import (
 	"fmt"
 	"os"
 
+	"guhwizard/internal/audit"
 	"guhwizard/internal/config"
+	"guhwizard/internal/escalate"
+	"guhwizard/internal/fs"
+	"guhwizard/internal/installer"
+	"guhwizard/internal/privileged"
 	"guhwizard/internal/root"
-	"guhwizard/internal/ui"
-
-	tea "github.com/charmbracelet/bubbletea"
 )
 
+// defaultBlueprint is read from the current directory.
+const defaultBlueprint = "install_config.yaml"
+
 func main() {
+	// Started by guhwizard itself as the target user, none of the rest applies
+	if len(os.Args) > 1 && os.Args[1] == "--"+fs.Flag {
+		if err := fs.RunTargetOp(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Subcommands are dispatched before the global flags are parsed
+	if len(os.Args) > 1 {
+		// Runs that were killed before their cleanup leave the sudoers rule
+		// behind. install and run remove it, the rest only mention it
+		switch os.Args[1] {
+		case "attach", "audit", "lint", "bundle", "revert-pacman-conf":
+			warnExpiredRule()
+		}
+
+		switch os.Args[1] {
+		case "attach":
+			os.Exit(runAttach(os.Args[2:]))
+		case "audit":
+			os.Exit(runAudit(os.Args[2:]))
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		case "bundle":
+			os.Exit(runBundle(os.Args[2:]))
+		case "install":
+			os.Exit(runInstall(os.Args[2:]))
+		case "run":
+			os.Exit(runRun(os.Args[2:]))
+		case "revert-pacman-conf":
+			os.Exit(runRevertPacmanConf(os.Args[2:]))
+		}
+	}
+
 	rootSetup := flag.Bool("root-setup", false, "Run root setup for sudo persistence")
+	rootTeardown := flag.Bool("root-teardown", false, "Remove the sudoers rule created by --root-setup")
+	escalation := flag.String("escalation", "", "Privilege escalation backend: sudo, doas or run0 (detected by default)")
+	privilegedHelper := flag.Bool(privileged.Flag, false, "Serve privileged operations on stdin/stdout (started by guhwizard itself)")
+	auditRecord := flag.Bool(audit.Flag, false, "Append the audit entry on stdin to the audit log (run by guhwizard itself)")
 	flag.Parse()
 
+	if *auditRecord {
+		if err := audit.Record(os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "Audit: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *privilegedHelper {
+		if err := privileged.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Privileged helper: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if *rootSetup {
-		if err := root.ConfigureSudoTimestamp(); err != nil {
+		backend, err := setupBackend(*escalation)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Root setup failed: %v\n", err)
 			os.Exit(1)
 		}
+		if backend.NoPersistence != "" {
+			fmt.Fprintf(os.Stderr, "Root setup is not available for %s: %s\n", backend.Name, backend.NoPersistence)
+			os.Exit(1)
+		}
+		if err := auditRootMode("root-setup", backend.Name, root.ConfigureSudoTimestamp); err != nil {
+			fmt.Fprintf(os.Stderr, "Root setup failed: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *rootTeardown {
+		via := "root"
+		if backend, err := setupBackend(*escalation); err == nil && audit.Invoker() != "" {
+			via = backend.Name
+		}
+		if err := auditRootMode("root-teardown", via, root.TeardownSudoers); err != nil {
+			fmt.Fprintf(os.Stderr, "Root teardown failed: %v\n", err)
+			os.Exit(1)
+		}
 		os.Exit(0)
 	}
 
-	// 1. Load the Installation Blueprint
-	// In a real release, you might embed this file into the binary using `//go:embed`
-	// so you don't need the external file at runtime.
-	cfg, err := config.Load("install_config.yaml")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
-		fmt.Println("Make sure 'install_config.yaml' is in the current directory.")
-		os.Exit(1)
+	// Without a subcommand, run the installer
+	args := flag.Args()
+	if *escalation != "" {
+		args = append([]string{"--escalation", *escalation}, args...)
 	}
+	os.Exit(runInstall(args))
+}
 
-	// 2. Initialize the UI Model with the Config
-	model := ui.NewModel(cfg)
+// setupBackend is the backend --root-setup was started through: the flag,
+// doas when it set DOAS_USER, otherwise the detected one.
+func setupBackend(name string) (*escalate.Backend, error) {
+	if name == "" && os.Getenv("DOAS_USER") != "" && os.Getenv("SUDO_USER") == "" {
+		name = "doas"
+	}
+	if name == "" {
+		return escalate.Detect()
+	}
+	return escalate.Lookup(name)
+}
+
+// selectBackend picks the escalation backend from the flag, falling back to
+// the blueprint and then to detection.
+func selectBackend(flagValue string, cfg *config.Config) error {
+	name := flagValue
+	if name == "" && cfg != nil {
+		name = cfg.Settings.Escalation
+	}
+	if err := escalate.Select(name); err != nil {
+		return fmt.Errorf("privilege escalation: %w", err)
+	}
+	return nil
+}
+
+func runRevertPacmanConf(args []string) int {
+	fset := flag.NewFlagSet("revert-pacman-conf", flag.ExitOnError)
+	fset.BoolVar(&installer.DirectEscalation, "without-helper", false, "Call the escalation backend for every command instead of starting the privileged helper")
+	fset.Parse(args)
+
+	if err := startPrivilegedHelper(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer installer.StopPrivilegedHelper()
+
+	if err := installer.RevertPacmanConf(func(s string) { fmt.Print(s) }); err != nil {
+		fmt.Fprintf(os.Stderr, "Revert failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
 
-	// 3. Run the Bubble Tea Program
-	p := tea.NewProgram(model, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
-		fmt.Printf("Alas, there's been an error: %v", err)
-		os.Exit(1)
+// startPrivilegedHelper asks for sudo once and keeps a root helper around for
+// the privileged steps. Without it nothing privileged runs, unless
+// --without-helper asked for every command to call sudo itself.
+func startPrivilegedHelper() error {
+	if err := installer.StartPrivilegedHelper(true); err != nil {
+		return fmt.Errorf("privileged helper unavailable: %v (use --without-helper to call %s directly)", err, escalate.Current().Name)
 	}
+	return nil
 }
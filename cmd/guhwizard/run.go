@@ -0,0 +1,178 @@
+// FILE: cmd/guhwizard/run.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+	"time"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/escalate"
+	"guhwizard/internal/root"
+	"guhwizard/internal/target"
+)
+
+// adminGroups are the groups distributions grant sudo to by default.
+var adminGroups = []string{"wheel", "sudo", "admin"}
+
+// noRightsMessages are how sudo says the user may not use it at all.
+var noRightsMessages = []string{"is not in the sudoers file", "may not run sudo", "is not allowed to execute"}
+
+// runRun is the one-step launcher: it sets up privilege persistence through
+// the escalation backend when needed (re-running itself as root), then
+// continues as the user with the installer.
+//
+//	guhwizard run [install flags]
+func runRun(args []string) int {
+	opts := parseInstallFlags("run", args)
+
+	// The blueprint may pick the backend. If it doesn't load, install reports why
+	cfg, _ := config.Load(opts.blueprint)
+	if err := selectBackend(opts.escalation, cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Installing for someone else needs root: the whole run goes through the backend
+	if opts.targetUser != "" && os.Geteuid() != 0 {
+		if u, err := user.Current(); err == nil && u.Username != opts.targetUser {
+			if _, err := target.Check(opts.targetUser); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			return runAsRoot(args)
+		}
+	}
+
+	// Before asking for root, which an unknown --target-user wouldn't need
+	if err := target.Init(opts.targetUser); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Println("--- GuhWizard ---")
+	removeSudoersRuleOnSignal()
+	enforceSudoersExpiry()
+	if err := ensurePersistence(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Println()
+	fmt.Println("Launching the installer...")
+	return install(opts)
+}
+
+// runAsRoot runs `guhwizard run args` again through the escalation backend,
+// which asks for the password on the terminal, and returns its exit code.
+func runAsRoot(args []string) int {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	backend := escalate.Current()
+	fmt.Printf("Installing for another user needs root, running guhwizard through %s...\n", backend.Name)
+
+	cmd := backend.Command(false, nil, append([]string{exe, "run", "--escalation", backend.Name}, args...)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "Cannot run guhwizard through %s: %v\n", backend.Name, err)
+		return 1
+	}
+	return 0
+}
+
+// ensurePersistence makes sure the install won't ask for the password at
+// every privileged step: a guhwizard sudoers rule or passwordless access is
+// kept, otherwise --root-setup runs through the backend (asking once).
+// doas and run0 can't persist, the privileged helper asks once instead.
+func ensurePersistence() error {
+	if os.Geteuid() == 0 {
+		return nil
+	}
+	u, err := user.Current()
+	if err != nil {
+		return err
+	}
+	backend := escalate.Current()
+
+	if rule, err := root.CurrentRule(); err == nil && rule != nil && rule.User == u.Username && !rule.Expired() {
+		fmt.Printf("Sudo persistence already configured for %s (until %s).\n", u.Username, rule.Expires.Format(time.Kitchen))
+		return nil
+	}
+	if backend.Validate() == nil {
+		fmt.Printf("%s works without a password, no setup needed.\n", backend.Name)
+		return nil
+	}
+	if backend.NoPersistence != "" {
+		fmt.Println(backend.NoPersistence)
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Println("[IMPORTANT] Root Setup Required")
+	fmt.Printf("guhwizard will set up a temporary %s rule for %s so you don't have to\n", backend.Name, u.Username)
+	fmt.Println("enter your password repeatedly during installation. It is removed when")
+	fmt.Printf("the installer exits, or on the next launch once it expires (%s).\n", root.RuleLifetime)
+	fmt.Println()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := backend.Command(false, nil, exe, "--root-setup", "--escalation", backend.Name)
+	cmd.Stdin, cmd.Stdout = os.Stdin, os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	if err := cmd.Run(); err != nil {
+		for _, msg := range noRightsMessages {
+			if strings.Contains(stderr.String(), msg) {
+				return noRightsError(u, backend)
+			}
+		}
+		return fmt.Errorf("root setup failed: %v", err)
+	}
+	return nil
+}
+
+// noRightsError explains what an administrator has to do for the user.
+func noRightsError(u *user.User, backend *escalate.Backend) error {
+	msg := fmt.Sprintf("Your user %s is not allowed to use %s, and guhwizard needs administrator rights to install packages.\n", u.Username, backend.Name)
+	if !inAdminGroup(u) {
+		msg += fmt.Sprintf("Ask an administrator to add you to the wheel group ('usermod -aG wheel %s', with %%wheel enabled in /etc/sudoers),\n", u.Username)
+		msg += "then log out and back in before running guhwizard again."
+	} else {
+		msg += "You are in an administrator group, but the sudoers configuration doesn't grant it. Ask an administrator to check /etc/sudoers."
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+func inAdminGroup(u *user.User) bool {
+	ids, err := u.GroupIds()
+	if err != nil {
+		return false
+	}
+	for _, id := range ids {
+		g, err := user.LookupGroupId(id)
+		if err != nil {
+			continue
+		}
+		for _, name := range adminGroups {
+			if g.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
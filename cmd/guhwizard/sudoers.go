@@ -0,0 +1,142 @@
+// FILE: cmd/guhwizard/sudoers.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"sync"
+	"syscall"
+	"time"
+
+	"guhwizard/internal/audit"
+	"guhwizard/internal/escalate"
+	"guhwizard/internal/root"
+)
+
+// enforceSudoersExpiry removes a sudoers rule left behind by a run that never
+// got to clean up (killed, terminal closed) once it has expired. That may
+// ask for the password, so only install and run call it.
+func enforceSudoersExpiry() {
+	rule := expiredRule()
+	if rule == nil {
+		return
+	}
+	fmt.Printf("The sudoers rule for %s expired at %s, removing it...\n", rule.User, rule.Expires.Format(time.DateTime))
+	teardownSudoers()
+}
+
+// warnExpiredRule only mentions an expired rule, for the other commands.
+func warnExpiredRule() {
+	if rule := expiredRule(); rule != nil {
+		fmt.Fprintf(os.Stderr, "Warning: the sudoers rule for %s expired at %s. The next install removes it, or run 'guhwizard --root-teardown' as root.\n",
+			rule.User, rule.Expires.Format(time.DateTime))
+	}
+}
+
+// expiredRule returns the recorded rule once it has expired, nil otherwise.
+func expiredRule() *root.Rule {
+	if os.Geteuid() == 0 {
+		return nil
+	}
+	rule, err := root.CurrentRule()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return nil
+	}
+	if rule == nil || !rule.Expired() {
+		return nil
+	}
+	return rule
+}
+
+// removeSudoersRule removes the invoking user's rule at the end of a run,
+// whether it finished, failed or was cancelled.
+func removeSudoersRule() {
+	rule, err := root.CurrentRule()
+	if err != nil || rule == nil {
+		return
+	}
+	if u, err := user.Current(); err != nil || u.Username != rule.User {
+		return
+	}
+
+	fmt.Println("Removing the temporary sudoers rule...")
+	teardownSudoers()
+}
+
+var (
+	signalOnce sync.Once
+	signalMu   sync.Mutex
+	// onSignal shuts the running TUI down, which then runs the deferred cleanup itself
+	onSignal func()
+)
+
+// removeSudoersRuleOnSignal removes the rule when the run is interrupted,
+// terminated or loses its terminal, which skips the deferred removeSudoersRule.
+// While the TUI runs, the first signal stops it instead and lets install clean up.
+func removeSudoersRuleOnSignal() {
+	signalOnce.Do(func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		go func() {
+			for sig := range sigs {
+				signalMu.Lock()
+				stop := onSignal
+				onSignal = nil
+				signalMu.Unlock()
+				if stop != nil {
+					stop()
+					continue
+				}
+				removeSudoersRule()
+				os.Exit(128 + int(sig.(syscall.Signal)))
+			}
+		}()
+	})
+}
+
+// setOnSignal sets what the next signal does instead of exiting (nil to exit again).
+func setOnSignal(stop func()) {
+	signalMu.Lock()
+	defer signalMu.Unlock()
+	onSignal = stop
+}
+
+// teardownSudoers runs --root-teardown through the escalation backend. While the rule is in
+// place the credentials are still cached, so this normally doesn't prompt.
+func teardownSudoers() {
+	if os.Geteuid() == 0 {
+		if err := root.TeardownSudoers(); err != nil {
+			fmt.Fprintf(os.Stderr, "Root teardown failed: %v\n", err)
+		}
+		return
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+	cmd := escalate.Current().Command(false, nil, exe, "--root-teardown")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not remove %s: %v\nRun 'sudo guhwizard --root-teardown' to remove it.\n", root.SudoersFile, err)
+	}
+}
+
+// auditRootMode runs --root-setup or --root-teardown, which change sudoers as
+// root, and records it in the audit log.
+func auditRootMode(flagName, via string, run func() error) error {
+	if os.Geteuid() != 0 {
+		// Refuses to run, nothing to record
+		return run()
+	}
+	entry := audit.Begin("exec", os.Args, "--"+flagName, audit.Invoker(), via)
+	err := run()
+	entry.Finish(err)
+	if logErr := audit.Append(entry); logErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not write the audit log: %v\n", logErr)
+	}
+	return err
+}
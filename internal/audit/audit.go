@@ -0,0 +1,155 @@
+// FILE: internal/audit/audit.go
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Dir holds the audit log. Only root writes there.
+const Dir = "/var/log/guhwizard"
+
+// File is the audit log, one JSON entry per line.
+const File = Dir + "/audit.log"
+
+// fsAppendFL is the append-only inode flag (FS_APPEND_FL in linux/fs.h).
+const fsAppendFL = 0x20
+
+// Flag (as --audit-record) appends the entry on stdin as root, for privileged
+// operations that don't go through the privileged helper.
+const Flag = "audit-record"
+
+// Entry records one privileged operation.
+type Entry struct {
+	Op       string    `json:"op"` // "exec", "write" or "hook"
+	Argv     []string  `json:"argv"`
+	Dir      string    `json:"cwd"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	ExitCode int       `json:"exit_code"`
+	Error    string    `json:"error,omitempty"`
+	// Cause is the blueprint entry that led to the operation, e.g. "shell/zsh"
+	Cause string `json:"cause,omitempty"`
+	User  string `json:"user"` // who ran guhwizard
+	Via   string `json:"via"`  // the helper, or the escalation backend
+}
+
+// Begin starts an entry for argv, Finish completes it.
+func Begin(op string, argv []string, cause, user, via string) Entry {
+	dir, _ := os.Getwd()
+	return Entry{Op: op, Argv: argv, Dir: dir, Start: time.Now(), Cause: cause, User: user, Via: via}
+}
+
+// Finish sets the end time and the outcome of err.
+func (e *Entry) Finish(err error) {
+	e.End = time.Now()
+	e.ExitCode = ExitCode(err)
+	if err != nil {
+		e.Error = err.Error()
+	}
+}
+
+// ExitCode is the exit status err carries: 0 without error, -1 when the
+// command didn't run or was killed.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// Open opens the log for appending, creating it append-only (chattr +a)
+// where the filesystem supports it so not even root can rewrite past entries
+// without removing the flag first. Root only.
+func Open() (*os.File, error) {
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if flags, err := unix.IoctlGetInt(int(f.Fd()), unix.FS_IOC_GETFLAGS); err == nil && flags&fsAppendFL == 0 {
+		unix.IoctlSetPointerInt(int(f.Fd()), unix.FS_IOC_SETFLAGS, flags|fsAppendFL)
+	}
+	return f, nil
+}
+
+// Write appends e to w as one line.
+func Write(w io.Writer, e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Append opens the log, appends e and closes it. Root only.
+func Append(e Entry) error {
+	f, err := Open()
+	if err != nil {
+		return err
+	}
+	if err := Write(f, e); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Record reads an entry from in and appends it, see Flag.
+func Record(in io.Reader) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("audit records must be written as root")
+	}
+	var e Entry
+	if err := json.NewDecoder(in).Decode(&e); err != nil {
+		return fmt.Errorf("invalid audit entry: %w", err)
+	}
+	// The caller can't claim to be someone else than the backend says
+	if name := Invoker(); name != "" {
+		e.User = name
+	}
+	return Append(e)
+}
+
+// Invoker is the user who ran a root process through sudo, doas or run0, empty if unknown.
+func Invoker() string {
+	if name := os.Getenv("SUDO_USER"); name != "" {
+		return name
+	}
+	return os.Getenv("DOAS_USER")
+}
+
+// Read returns every entry of the log, oldest first. Lines that don't parse are skipped.
+func Read() ([]Entry, error) {
+	f, err := os.Open(File)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
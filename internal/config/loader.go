@@ -4,13 +4,20 @@ package config
 import (
 	"os"
 
+	"guhwizard/internal/pacmanconf"
+
 	"gopkg.in/yaml.v3"
 )
 
 type Item struct {
 	Name        string `yaml:"name"`
 	Description string `yaml:"desc"`
+	Hooks       []Hook `yaml:"hooks"`
 	Selected    bool   `yaml:"-"`
+
+	// Filled from the local package database at startup
+	Installed        bool   `yaml:"-"`
+	InstalledVersion string `yaml:"-"`
 }
 
 type Step struct {
@@ -18,6 +25,27 @@ type Step struct {
 	Title string `yaml:"title"`
 	Type  string `yaml:"type"`
 	Items []Item `yaml:"items"`
+	Hooks []Hook `yaml:"hooks"`
+	// System steps toggle system settings instead of installing packages
+	System bool `yaml:"system"`
+}
+
+// Hook is a blueprint-declared command run at a fixed point of the install.
+type Hook struct {
+	Name    string            `yaml:"name"`
+	Command string            `yaml:"command"`
+	Dir     string            `yaml:"dir"`
+	Env     map[string]string `yaml:"env"`
+	RunAs   string            `yaml:"run_as"`  // "user" (default) or "root"
+	Timeout string            `yaml:"timeout"` // Go duration, e.g. "10m". Empty means no limit.
+}
+
+// Hooks are the global lifecycle hooks, in the order they run.
+type Hooks struct {
+	PreInstall   []Hook `yaml:"pre_install"`
+	PostPackages []Hook `yaml:"post_packages"`
+	PostDotfiles []Hook `yaml:"post_dotfiles"`
+	PostInstall  []Hook `yaml:"post_install"`
 }
 
 type Script struct {
@@ -36,12 +64,57 @@ type DotfilesConfig struct {
 	Items     []DotfileItem `yaml:"items"`
 }
 
+// PacmanConfSettings feeds the "pacman" system step.
+type PacmanConfSettings struct {
+	ParallelDownloads int               `yaml:"parallel_downloads"`
+	Repos             []pacmanconf.Repo `yaml:"repos"`
+}
+
+// ThirdPartyRepo is an extra pacman repository such as Chaotic-AUR.
+type ThirdPartyRepo struct {
+	Name     string   `yaml:"name"`
+	SigLevel string   `yaml:"sig_level"`
+	Servers  []string `yaml:"servers"`
+	// Mirrorlist is the Include file installed by one of Packages
+	Mirrorlist string `yaml:"mirrorlist"`
+	// Packages are keyring/mirrorlist package files or URLs, installed with pacman -U
+	Packages  []string `yaml:"packages"`
+	KeyIDs    []string `yaml:"key_ids"`
+	Keyserver string   `yaml:"keyserver"`
+}
+
 type Config struct {
 	Settings struct {
 		AURHelper       string         `yaml:"aur_helper"`
 		BasePackages    []string       `yaml:"base_packages"`
 		ExternalScripts []Script       `yaml:"external_scripts"`
 		Dotfiles        DotfilesConfig `yaml:"dotfiles"`
+		Hooks           Hooks          `yaml:"hooks"`
+
+		// AURHelperCommits pins the AUR helper's git commit, keyed by package name
+		AURHelperCommits map[string]string `yaml:"aur_helper_commits"`
+		// SkipPKGBUILDReview builds the AUR helper without asking first
+		SkipPKGBUILDReview bool `yaml:"skip_pkgbuild_review"`
+		// AURURL is where the built-in AUR builder clones from. A local directory works too.
+		AURURL string `yaml:"aur_url"`
+
+		// ErrorPolicy decides what happens when some packages fail: "abort" (default) or "continue"
+		ErrorPolicy string `yaml:"error_policy"`
+		// PreselectInstalled pre-checks items that are already installed
+		PreselectInstalled bool `yaml:"preselect_installed"`
+		// Escalation is the privilege escalation backend: sudo, doas or run0 (detected when empty)
+		Escalation string `yaml:"escalation"`
+		// SkipSystemUpgrade installs without the full -Syu first (risks a partial upgrade)
+		SkipSystemUpgrade bool `yaml:"skip_system_upgrade"`
+		// StallTimeout (Go duration) warns about a command printing nothing for that long.
+		// Empty means 10m, "0" turns the warning off.
+		StallTimeout string `yaml:"stall_timeout"`
+		// UserServices are systemd user units enabled for the target user after the dotfiles
+		UserServices []string `yaml:"user_services"`
+
+		PacmanConf PacmanConfSettings `yaml:"pacman_conf"`
+		// Repositories are set up before packages, which then prefer them over AUR builds
+		Repositories []ThirdPartyRepo `yaml:"repositories"`
 	} `yaml:"settings"`
 	Steps []Step `yaml:"steps"`
 }
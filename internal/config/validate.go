@@ -0,0 +1,141 @@
+// FILE: internal/config/validate.go
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"guhwizard/internal/pacmanconf"
+)
+
+// Validate checks the blueprint for structural mistakes and returns every problem found.
+func Validate(cfg *Config) []error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	hasAUR := false
+	stepIDs := make(map[string]bool)
+	for i, step := range cfg.Steps {
+		where := fmt.Sprintf("steps[%d]", i)
+		if step.ID == "" {
+			add("%s: missing id", where)
+		} else {
+			where = fmt.Sprintf("step %q", step.ID)
+			if stepIDs[step.ID] {
+				add("%s: duplicate step id", where)
+			}
+			stepIDs[step.ID] = true
+		}
+		if step.ID == "aur" {
+			hasAUR = true
+		}
+
+		if step.Type != "single" && step.Type != "multi" {
+			add("%s: type must be single or multi, got %q", where, step.Type)
+		}
+		if len(step.Items) == 0 {
+			add("%s: has no items", where)
+		}
+
+		names := make(map[string]bool)
+		for j, item := range step.Items {
+			if item.Name == "" {
+				add("%s: items[%d] has no name", where, j)
+				continue
+			}
+			if names[item.Name] {
+				add("%s: duplicate item %q", where, item.Name)
+			}
+			names[item.Name] = true
+			errs = append(errs, validateHooks(fmt.Sprintf("%s item %q", where, item.Name), item.Hooks)...)
+		}
+		errs = append(errs, validateHooks(where, step.Hooks)...)
+	}
+
+	if !hasAUR && cfg.Settings.AURHelper == "" {
+		add("no aur step and no settings.aur_helper: nothing selects an AUR helper")
+	}
+
+	switch cfg.Settings.ErrorPolicy {
+	case "", "abort", "continue":
+	default:
+		add("error_policy must be abort or continue, got %q", cfg.Settings.ErrorPolicy)
+	}
+
+	switch cfg.Settings.Escalation {
+	case "", "sudo", "doas", "run0":
+	default:
+		add("escalation must be sudo, doas or run0, got %q", cfg.Settings.Escalation)
+	}
+
+	if cfg.Settings.StallTimeout != "" {
+		if _, err := time.ParseDuration(cfg.Settings.StallTimeout); err != nil {
+			add("invalid stall_timeout %q", cfg.Settings.StallTimeout)
+		}
+	}
+
+	for _, unit := range cfg.Settings.UserServices {
+		if unit == "" || strings.Contains(unit, "/") {
+			add("invalid user_services entry %q", unit)
+		}
+	}
+
+	hooks := cfg.Settings.Hooks
+	errs = append(errs, validateHooks("hooks.pre_install", hooks.PreInstall)...)
+	errs = append(errs, validateHooks("hooks.post_packages", hooks.PostPackages)...)
+	errs = append(errs, validateHooks("hooks.post_dotfiles", hooks.PostDotfiles)...)
+	errs = append(errs, validateHooks("hooks.post_install", hooks.PostInstall)...)
+
+	for i, repo := range cfg.Settings.Repositories {
+		if repo.Name == "" {
+			add("repositories[%d]: missing name", i)
+		}
+		if len(repo.Servers) == 0 && repo.Mirrorlist == "" {
+			add("repositories[%d]: needs servers or a mirrorlist", i)
+		}
+		section := pacmanconf.Repo{Name: repo.Name, SigLevel: repo.SigLevel, Servers: repo.Servers, Include: repo.Mirrorlist}
+		if err := section.Check(); err != nil && repo.Name != "" {
+			add("repositories[%d]: %v", i, err)
+		}
+	}
+
+	// The privileged helper refuses these too, see pacmanconf.Edits.Check
+	for i, repo := range cfg.Settings.PacmanConf.Repos {
+		if err := repo.Check(); err != nil {
+			add("pacman_conf.repos[%d]: %v", i, err)
+		}
+	}
+	edits := pacmanconf.Edits{ParallelDownloads: cfg.Settings.PacmanConf.ParallelDownloads}
+	if err := edits.Check(); err != nil {
+		add("pacman_conf: %v", err)
+	}
+
+	for i, item := range cfg.Settings.Dotfiles.Items {
+		if item.Src == "" || item.Dest == "" {
+			add("dotfiles.items[%d]: src and dest are required", i)
+		}
+	}
+
+	return errs
+}
+
+func validateHooks(where string, hooks []Hook) []error {
+	var errs []error
+	for i, h := range hooks {
+		if h.Command == "" {
+			errs = append(errs, fmt.Errorf("%s: hook %d has no command", where, i+1))
+		}
+		if h.RunAs != "" && h.RunAs != "user" && h.RunAs != "root" {
+			errs = append(errs, fmt.Errorf("%s: hook %d: run_as must be user or root, got %q", where, i+1, h.RunAs))
+		}
+		if h.Timeout != "" {
+			if _, err := time.ParseDuration(h.Timeout); err != nil {
+				errs = append(errs, fmt.Errorf("%s: hook %d: invalid timeout %q", where, i+1, h.Timeout))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,65 @@
+// FILE: internal/control/client.go
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+)
+
+func dial(path string, req Request) (net.Conn, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("no running install found at %s: %w", path, err)
+	}
+
+	data, _ := json.Marshal(req)
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Send issues a single command and returns the server's reply.
+func Send(path string, req Request) (*Response, error) {
+	conn, err := dial(path, req)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if !resp.OK {
+		return &resp, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// StreamLogs prints the log history and then follows new lines until the install ends.
+func StreamLogs(path string, onLine func(string)) error {
+	conn, err := dial(path, Request{Cmd: "logs"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var resp Response
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			return err
+		}
+		if !resp.OK {
+			return errors.New(resp.Error)
+		}
+		onLine(resp.Line)
+	}
+	return scanner.Err()
+}
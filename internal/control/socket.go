@@ -0,0 +1,217 @@
+// FILE: internal/control/socket.go
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const SocketName = "guhwizard.sock"
+
+// How long Close waits for clients to drain before hanging up on them.
+const closeGrace = time.Second
+
+// Prompt is a question the running install is waiting on.
+type Prompt struct {
+	ID       int      `json:"id"`
+	Question string   `json:"question"`
+	Choices  []string `json:"choices,omitempty"`
+}
+
+// Status is a snapshot of the running install.
+type Status struct {
+	Running   bool     `json:"running"`
+	Cancelled bool     `json:"cancelled"`
+	Step      string   `json:"step"`
+	Percent   float64  `json:"percent"`
+	Prompts   []Prompt `json:"prompts,omitempty"`
+}
+
+// Target is whatever the socket is steering (the engine's Runner).
+type Target interface {
+	Status() Status
+	// Subscribe returns the log history so far plus a channel of new lines.
+	// The returned func must be called to unsubscribe.
+	Subscribe() ([]string, <-chan string, func())
+	Cancel()
+	Answer(id int, answer string) error
+}
+
+// Request is a single client command, sent as one JSON line.
+type Request struct {
+	Cmd    string `json:"cmd"` // status, logs, cancel, answer
+	ID     int    `json:"id,omitempty"`
+	Answer string `json:"answer,omitempty"`
+}
+
+// Response is sent back as one JSON line (or many, for "logs").
+type Response struct {
+	OK     bool    `json:"ok"`
+	Error  string  `json:"error,omitempty"`
+	Status *Status `json:"status,omitempty"`
+	Line   string  `json:"line,omitempty"`
+}
+
+// SocketPath returns the control socket location inside $XDG_RUNTIME_DIR.
+func SocketPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return "", errors.New("XDG_RUNTIME_DIR is not set")
+	}
+	return filepath.Join(dir, SocketName), nil
+}
+
+// Server accepts control connections for one install run.
+type Server struct {
+	path     string
+	listener net.Listener
+	target   Target
+	wg       sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// Listen creates the socket at path and starts serving target.
+// The socket is chmod'ed to 0600 so only the owning user can connect.
+func Listen(path string, target Target) (*Server, error) {
+	// A leftover socket from a crashed run is fine to replace,
+	// a live one means another install is in progress.
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another install is already running (%s)", path)
+		}
+		os.Remove(path)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+
+	s := &Server{
+		path:     path,
+		listener: l,
+		target:   target,
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+
+	return s, nil
+}
+
+// Close stops accepting, drops connected clients and removes the socket.
+// Clients get a moment to receive what is already queued for them.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(closeGrace):
+		s.mu.Lock()
+		for c := range s.conns {
+			c.Close()
+		}
+		s.mu.Unlock()
+		<-done
+	}
+
+	os.Remove(s.path)
+	return err
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	enc := json.NewEncoder(conn)
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return
+	}
+
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		enc.Encode(Response{Error: fmt.Sprintf("bad request: %v", err)})
+		return
+	}
+
+	switch req.Cmd {
+	case "status":
+		st := s.target.Status()
+		enc.Encode(Response{OK: true, Status: &st})
+
+	case "cancel":
+		s.target.Cancel()
+		enc.Encode(Response{OK: true})
+
+	case "answer":
+		if err := s.target.Answer(req.ID, req.Answer); err != nil {
+			enc.Encode(Response{Error: err.Error()})
+			return
+		}
+		enc.Encode(Response{OK: true})
+
+	case "logs":
+		history, lines, unsubscribe := s.target.Subscribe()
+		defer unsubscribe()
+
+		for _, l := range history {
+			if err := enc.Encode(Response{OK: true, Line: l}); err != nil {
+				return
+			}
+		}
+		for l := range lines {
+			if err := enc.Encode(Response{OK: true, Line: l}); err != nil {
+				return
+			}
+		}
+
+	default:
+		enc.Encode(Response{Error: fmt.Sprintf("unknown command %q", req.Cmd)})
+	}
+}
@@ -0,0 +1,196 @@
+// FILE: internal/engine/control.go
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"guhwizard/internal/control"
+)
+
+// Keep the last few hundred lines around for clients attaching mid-run.
+const logHistorySize = 500
+
+// PromptMsg tells the TUI a prompt appeared, or (Done) that it was answered elsewhere.
+type PromptMsg struct {
+	Prompt control.Prompt
+	Done   bool
+}
+
+type pendingPrompt struct {
+	prompt control.Prompt
+	answer chan string
+}
+
+// Status implements control.Target.
+func (r *Runner) Status() control.Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st := control.Status{
+		Running:   r.running,
+		Cancelled: r.cancelled,
+		Step:      r.status.CurrentStep,
+		Percent:   r.status.CurrentPercent,
+	}
+	for _, p := range r.prompts {
+		st.Prompts = append(st.Prompts, p.prompt)
+	}
+	return st
+}
+
+// Subscribe implements control.Target.
+func (r *Runner) Subscribe() ([]string, <-chan string, func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := append([]string(nil), r.history...)
+	ch := make(chan string, 100)
+	if !r.running {
+		close(ch)
+		return history, ch, func() {}
+	}
+	r.subs[ch] = struct{}{}
+
+	return history, ch, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if _, ok := r.subs[ch]; ok {
+			delete(r.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Cancel implements control.Target. The running command is interrupted
+// and the install stops instead of going on with the next phase.
+func (r *Runner) Cancel() {
+	r.mu.Lock()
+	if r.cancelled {
+		r.mu.Unlock()
+		return
+	}
+	r.cancelled = true
+	prompts := r.prompts
+	r.prompts = make(map[int]*pendingPrompt)
+	stop := r.stopCommands
+	r.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+
+	// Unblock anything waiting on an answer
+	for _, p := range prompts {
+		close(p.answer)
+		r.notifyPrompt(PromptMsg{Prompt: p.prompt, Done: true})
+	}
+	r.Log("Cancellation requested, stopping the running command...\n")
+}
+
+// Answer implements control.Target.
+func (r *Runner) Answer(id int, answer string) error {
+	r.mu.Lock()
+	p, ok := r.prompts[id]
+	if ok {
+		delete(r.prompts, id)
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no pending prompt with id %d", id)
+	}
+	p.answer <- answer
+	r.notifyPrompt(PromptMsg{Prompt: p.prompt, Done: true})
+	return nil
+}
+
+// notifyPrompt never blocks: the control socket can answer even if the TUI is busy.
+func (r *Runner) notifyPrompt(msg PromptMsg) {
+	if r.PromptChan == nil {
+		return
+	}
+	select {
+	case r.PromptChan <- msg:
+	default:
+	}
+}
+
+// Ask blocks until the question is answered, either from the TUI or the control socket.
+func (r *Runner) Ask(question string, choices []string) (string, error) {
+	r.mu.Lock()
+	if r.cancelled {
+		r.mu.Unlock()
+		return "", ErrCancelled
+	}
+	r.nextPrompt++
+	p := &pendingPrompt{
+		prompt: control.Prompt{ID: r.nextPrompt, Question: question, Choices: choices},
+		answer: make(chan string, 1),
+	}
+	r.prompts[p.prompt.ID] = p
+	r.mu.Unlock()
+
+	r.Log(fmt.Sprintf("Waiting for answer (prompt %d): %s\n", p.prompt.ID, question))
+	r.notifyPrompt(PromptMsg{Prompt: p.prompt})
+
+	answer, ok := <-p.answer
+	if !ok {
+		return "", ErrCancelled
+	}
+	return answer, nil
+}
+
+// ErrCancelled is returned when the install was cancelled through the control socket.
+var ErrCancelled = errors.New("installation cancelled")
+
+func (r *Runner) checkCancelled() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancelled {
+		return ErrCancelled
+	}
+	return nil
+}
+
+// startControl opens the control socket for the duration of Install and
+// returns the context Cancel stops the install's commands through.
+// Failing to open it is not fatal, the TUI still works without it.
+func (r *Runner) startControl() (context.Context, func()) {
+	r.mu.Lock()
+	r.running = true
+	r.cancelled = false
+	r.history = nil
+	ctx, stop := context.WithCancel(context.Background())
+	r.stopCommands = stop
+	r.mu.Unlock()
+
+	path, err := control.SocketPath()
+	var srv *control.Server
+	if err == nil {
+		srv, err = control.Listen(path, r)
+	}
+	if err != nil {
+		r.Log(fmt.Sprintf("Control socket unavailable: %v\n", err))
+	} else {
+		r.Log(fmt.Sprintf("Control socket listening on %s (use 'guhwizard attach')\n", path))
+	}
+
+	return ctx, func() {
+		stop()
+
+		r.mu.Lock()
+		r.running = false
+		r.stopCommands = nil
+		for ch := range r.subs {
+			close(ch)
+		}
+		r.subs = make(map[chan string]struct{})
+		r.mu.Unlock()
+
+		if srv != nil {
+			srv.Close()
+		}
+	}
+}
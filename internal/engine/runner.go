@@ -2,9 +2,13 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"guhwizard/internal/config"
 	"guhwizard/internal/installer"
+	"strings"
+	"sync"
+	"time"
 )
 
 type ProgressMsg struct {
@@ -16,46 +20,176 @@ type Runner struct {
 	Config       *config.Config
 	LogChan      chan string
 	ProgressChan chan ProgressMsg
+	PromptChan   chan PromptMsg
+	LiveChan     chan []string
+
+	// Repo and AUR override the package backends (e.g. with installer.FakePackageManager).
+	// Nil means pacman for Repo and the configured AUR helper for AUR.
+	Repo installer.RepoManager
+	AUR  installer.PackageManager
+
+	// State shared with the control socket
+	mu         sync.Mutex
+	running    bool
+	cancelled  bool
+	status     ProgressMsg
+	history    []string
+	subs       map[chan string]struct{}
+	prompts    map[int]*pendingPrompt
+	nextPrompt int
+	// stopCommands cancels the context of the commands the install runs
+	stopCommands func()
 }
 
-func NewRunner(cfg *config.Config, logChan chan string, progChan chan ProgressMsg) *Runner {
+func NewRunner(cfg *config.Config, logChan chan string, progChan chan ProgressMsg, promptChan chan PromptMsg, liveChan chan []string) *Runner {
 	return &Runner{
 		Config:       cfg,
 		LogChan:      logChan,
 		ProgressChan: progChan,
+		PromptChan:   promptChan,
+		LiveChan:     liveChan,
+		subs:         make(map[chan string]struct{}),
+		prompts:      make(map[int]*pendingPrompt),
 	}
 }
 
 func (r *Runner) Log(msg string) {
+	r.mu.Lock()
+	line := strings.TrimRight(msg, "\n")
+	r.history = append(r.history, line)
+	if len(r.history) > logHistorySize {
+		r.history = r.history[len(r.history)-logHistorySize:]
+	}
+	for ch := range r.subs {
+		// Slow clients miss lines rather than stalling the install
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+	r.mu.Unlock()
+
 	if r.LogChan != nil {
 		r.LogChan <- msg
 	}
 }
 
+// showLive passes on the lines a command is redrawing. Only the latest state
+// matters, so one the TUI hasn't picked up yet is replaced rather than queued.
+func (r *Runner) showLive(lines []string) {
+	if r.LiveChan == nil {
+		return
+	}
+	select {
+	case <-r.LiveChan:
+	default:
+	}
+	select {
+	case r.LiveChan <- lines:
+	default:
+	}
+}
+
 func (r *Runner) reportProgress(pct float64, step string) {
+	r.mu.Lock()
+	r.status = ProgressMsg{CurrentPercent: pct, CurrentStep: step}
+	r.mu.Unlock()
+
 	if r.ProgressChan != nil {
 		r.ProgressChan <- ProgressMsg{CurrentPercent: pct, CurrentStep: step}
 	}
 }
 
-func (r *Runner) Install() error {
+func (r *Runner) Install() (err error) {
+	ctx, stopControl := r.startControl()
+	defer stopControl()
+	defer r.useInstaller(ctx)()
+	// A command stopped by Cancel fails in its own way, report the cancellation
+	defer func() {
+		if err != nil && r.checkCancelled() != nil {
+			err = ErrCancelled
+		}
+	}()
+
+	hooks := r.Config.Settings.Hooks
+	if err := installer.RunHooks(r.Config, "pre_install", hooks.PreInstall, nil, r.Log); err != nil {
+		return err
+	}
+
+	if installer.IsOffline() {
+		installer.SetAuditCause("offline repository")
+		r.reportProgress(0.05, "Syncing Offline Repository...")
+		if err := (&installer.Pacman{}).Refresh(r.Log); err != nil {
+			return fmt.Errorf("failed to sync the offline repository: %w", err)
+		}
+	}
+
+	if len(r.Config.Settings.Repositories) > 0 && !installer.IsOffline() {
+		installer.SetAuditCause("settings.repositories")
+		r.reportProgress(0.06, "Adding Third-Party Repositories...")
+		if err := installer.PrepareRepositories(r.Config, r.Log); err != nil {
+			return err
+		}
+	}
+
+	// Also adds the third-party repo sections, then refreshes so they are
+	// preferred over AUR builds when packages get classified
+	installer.SetAuditCause("settings.pacman_conf")
+	r.reportProgress(0.07, "Tuning pacman.conf...")
+	changed, err := installer.ApplyPacmanConf(r.Config, r.Log)
+	if err != nil {
+		return err
+	}
+	if changed && !installer.IsOffline() {
+		if err := (&installer.Pacman{}).Refresh(r.Log); err != nil {
+			return err
+		}
+	}
+
+	// Full upgrade first, installing into a partially upgraded system breaks libraries
+	installer.SetAuditCause("system upgrade")
+	r.reportProgress(0.08, "Upgrading System...")
+	if err := installer.UpgradeSystem(r.Config, r.Log); err != nil {
+		return err
+	}
+
+	if err := r.checkCancelled(); err != nil {
+		return err
+	}
+
+	installer.SetAuditCause("aur/" + r.Config.Settings.AURHelper)
 	r.reportProgress(0.1, fmt.Sprintf("Installing AUR Helper (%s)...", r.Config.Settings.AURHelper))
 	if err := installer.InstallAURHelper(r.Config, r.Log); err != nil {
 		return err
 	}
 
+	if err := r.checkCancelled(); err != nil {
+		return err
+	}
+
 	// 3. Install Packages (Base + Selected)
+	installer.SetAuditCause("packages")
 	r.reportProgress(0.2, "Installing Packages...")
-	if err := installer.InstallPackages(r.Config, r.Log); err != nil {
+	aur, err := r.installPackages()
+	if err != nil {
+		return err
+	}
+
+	if err := r.checkCancelled(); err != nil {
 		return err
 	}
 
 	// 4. External Scripts
+	installer.SetAuditCause("settings.external_scripts")
 	r.reportProgress(0.5, "Running Setup Scripts...")
 	if err := installer.RunExternalScripts(r.Config, r.Log); err != nil {
 		return err
 	}
 
+	if err := r.checkCancelled(); err != nil {
+		return err
+	}
+
 	// 5. System Configuration (SDDM, Shell, Terminal)
 	r.reportProgress(0.6, "Configuring System...")
 
@@ -64,6 +198,7 @@ func (r *Runner) Install() error {
 		if step.ID == "dm" {
 			for _, item := range step.Items {
 				if item.Name == "sddm" && item.Selected {
+					installer.SetAuditCause("dm/sddm")
 					r.reportProgress(0.65, "Configuring SDDM...")
 					if err := installer.ConfigureSDDM(r.Config, r.Log); err != nil {
 						r.Log(fmt.Sprintf("Error configuring SDDM: %v", err))
@@ -78,6 +213,7 @@ func (r *Runner) Install() error {
 		if step.ID == "terminals" {
 			for _, item := range step.Items {
 				if item.Selected {
+					installer.SetAuditCause("terminals/" + item.Name)
 					if err := installer.PatchTerminal(item.Name, r.Log); err != nil {
 						r.Log(fmt.Sprintf("Error patching terminal: %v", err))
 					}
@@ -91,6 +227,7 @@ func (r *Runner) Install() error {
 		if step.ID == "shell" {
 			for _, item := range step.Items {
 				if item.Selected && item.Name != "bash" { // bash is default usually
+					installer.SetAuditCause("shell/" + item.Name)
 					if err := installer.ChangeShell(item.Name, r.Log); err != nil {
 						r.Log(fmt.Sprintf("Error changing shell: %v", err))
 					}
@@ -99,12 +236,126 @@ func (r *Runner) Install() error {
 		}
 	}
 
+	if err := r.checkCancelled(); err != nil {
+		return err
+	}
+
+	// Step and item hooks run once their packages are installed and configured
+	if err := r.runSelectionHooks(); err != nil {
+		return err
+	}
+
 	// 6. Dotfiles
+	installer.SetAuditCause("settings.dotfiles")
 	r.reportProgress(0.8, "Installing Dotfiles...")
 	if err := installer.ProcessDotfiles(r.Config, r.Log); err != nil {
 		return err
 	}
+	installer.SetAuditCause("settings.user_services")
+	if err := installer.EnableUserServices(r.Config, r.Log); err != nil {
+		return err
+	}
+	if err := installer.RunHooks(r.Config, "post_dotfiles", hooks.PostDotfiles, nil, r.Log); err != nil {
+		return err
+	}
+
+	r.reportProgress(0.95, "Running Post-Install Hooks...")
+	if err := installer.RunHooks(r.Config, "post_install", hooks.PostInstall, nil, r.Log); err != nil {
+		return err
+	}
+
+	// A failed lockfile doesn't undo a finished install
+	if err := installer.WriteLockfile(installer.LockfileName, r.Config, aur, r.Log); err != nil {
+		r.Log(fmt.Sprintf("Warning: could not write %s: %v\n", installer.LockfileName, err))
+	}
 
 	r.reportProgress(1.0, "Installation Complete!")
 	return nil
 }
+
+// useInstaller points the installer's hooks into the engine (cancellation,
+// prompts, live output, the stall timeout) at r for the duration of Install.
+// The returned func puts back what was there before.
+func (r *Runner) useInstaller(ctx context.Context) (restore func()) {
+	prevCtx, prevPrompt, prevLive, prevStall := installer.Context(), installer.UserPrompt, installer.LiveOutput, installer.StallTimeout
+
+	installer.SetContext(ctx)
+	installer.UserPrompt = r.Ask
+	installer.LiveOutput = r.showLive
+	installer.StallTimeout = installer.DefaultStallTimeout
+	if timeout, err := time.ParseDuration(r.Config.Settings.StallTimeout); err == nil {
+		installer.StallTimeout = timeout
+	}
+
+	return func() {
+		installer.SetContext(prevCtx)
+		installer.UserPrompt = prevPrompt
+		installer.LiveOutput = prevLive
+		installer.StallTimeout = prevStall
+		installer.SetAuditCause("")
+	}
+}
+
+// runSelectionHooks runs the hooks of every step with at least one selected item,
+// followed by the hooks of each selected item.
+func (r *Runner) runSelectionHooks() error {
+	for _, step := range r.Config.Steps {
+		var selected []config.Item
+		for _, item := range step.Items {
+			if item.Selected {
+				selected = append(selected, item)
+			}
+		}
+		if len(selected) == 0 {
+			continue
+		}
+
+		stepEnv := []string{"GUH_STEP=" + step.ID}
+		if err := installer.RunHooks(r.Config, "step:"+step.ID, step.Hooks, stepEnv, r.Log); err != nil {
+			return err
+		}
+
+		for _, item := range selected {
+			itemEnv := append(stepEnv, "GUH_ITEM="+item.Name)
+			if err := installer.RunHooks(r.Config, "item:"+item.Name, item.Hooks, itemEnv, r.Log); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// installPackages installs the base packages and the selection through
+// Repo and AUR, then runs the post_packages hooks. It returns the AUR backend
+// for the lockfile.
+func (r *Runner) installPackages() (installer.PackageManager, error) {
+	repo, aur, err := r.packageManagers()
+	if err != nil {
+		return nil, err
+	}
+	if err := installer.InstallPackages(r.Config, repo, aur, r.Log); err != nil {
+		return nil, err
+	}
+	if err := installer.RunHooks(r.Config, "post_packages", r.Config.Settings.Hooks.PostPackages, nil, r.Log); err != nil {
+		return nil, err
+	}
+	return aur, nil
+}
+
+func (r *Runner) packageManagers() (installer.RepoManager, installer.PackageManager, error) {
+	repo, aur := r.Repo, r.AUR
+	if repo == nil {
+		repo = &installer.Pacman{}
+	}
+	if aur == nil {
+		pm, err := installer.NewAURBackend(r.Config)
+		if err != nil {
+			return nil, nil, err
+		}
+		if note := installer.AURHelperOverride(r.Config); note != "" {
+			r.Log(fmt.Sprintf("Note: %s.\n", note))
+		}
+		aur = pm
+	}
+	return repo, aur, nil
+}
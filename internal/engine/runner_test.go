@@ -0,0 +1,106 @@
+// FILE: internal/engine/runner_test.go
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/installer"
+)
+
+// newTestRunner returns a runner whose Repo and AUR are one fake, so Calls
+// shows the order of every transaction. Packages in aur come from the AUR,
+// the others from the repos.
+func newTestRunner(base []string, selected []string, aur ...string) (*Runner, *installer.FakePackageManager) {
+	cfg := &config.Config{}
+	cfg.Settings.BasePackages = base
+	step := config.Step{ID: "apps", Type: "multi"}
+	for _, name := range selected {
+		step.Items = append(step.Items, config.Item{Name: name, Selected: true})
+	}
+	cfg.Steps = []config.Step{step}
+
+	var available []installer.PackageInfo
+	for _, name := range append(append([]string{}, base...), selected...) {
+		available = append(available, installer.PackageInfo{Name: name, Version: "1.0-1", Repository: "extra"})
+	}
+	fake := installer.NewFakePackageManager(available...)
+	for _, name := range aur {
+		fake.Available[name] = installer.PackageInfo{Name: name, Version: "1.0-1", Repository: "aur"}
+	}
+
+	r := NewRunner(cfg, nil, nil, nil, nil)
+	r.Repo, r.AUR = fake, fake
+	return r, fake
+}
+
+func TestInstallPackagesRepoBeforeAUR(t *testing.T) {
+	r, fake := newTestRunner([]string{"glibc", "git"}, []string{"paru-bin", "firefox", "wlogout"}, "paru-bin", "wlogout")
+
+	if _, err := r.installPackages(); err != nil {
+		t.Fatalf("installPackages: %v", err)
+	}
+
+	want := []string{"install glibc git firefox", "install paru-bin", "install wlogout"}
+	if !reflect.DeepEqual(fake.Calls, want) {
+		t.Errorf("calls = %q, want %q", fake.Calls, want)
+	}
+}
+
+func TestInstallPackagesBisectsFailedBatch(t *testing.T) {
+	r, fake := newTestRunner([]string{"a", "b", "c", "d"}, nil)
+	fake.Fail["c"] = errors.New("conflicting files")
+
+	_, err := r.installPackages()
+	if err == nil || !strings.Contains(err.Error(), "c (") {
+		t.Fatalf("err = %v, want c reported as failed", err)
+	}
+
+	want := []string{"install a b c d", "install a b", "install c d", "install c", "install d"}
+	if !reflect.DeepEqual(fake.Calls, want) {
+		t.Errorf("calls = %q, want %q", fake.Calls, want)
+	}
+	if got := fake.InstalledNames(); !reflect.DeepEqual(got, []string{"a", "b", "d"}) {
+		t.Errorf("installed = %q, want everything but c", got)
+	}
+}
+
+func TestInstallPackagesErrorPolicy(t *testing.T) {
+	r, fake := newTestRunner([]string{"glibc"}, []string{"broken"}, "broken")
+	fake.Fail["broken"] = errors.New("build failed")
+
+	if _, err := r.installPackages(); err == nil {
+		t.Fatal("abort policy: want an error for the failed AUR package")
+	}
+
+	r.Config.Settings.ErrorPolicy = "continue"
+	if _, err := r.installPackages(); err != nil {
+		t.Fatalf("continue policy: %v", err)
+	}
+	if got := fake.InstalledNames(); !reflect.DeepEqual(got, []string{"glibc"}) {
+		t.Errorf("installed = %q, want [glibc]", got)
+	}
+}
+
+func TestInstallPackagesSkipsUnknownAndKeepsInstalled(t *testing.T) {
+	r, fake := newTestRunner([]string{"git"}, []string{"kitty"})
+	fake.Installed["git"] = fake.Available["git"]
+	delete(fake.Available, "kitty")
+	r.Config.Settings.ErrorPolicy = "continue"
+
+	if _, err := r.installPackages(); err != nil {
+		t.Fatalf("installPackages: %v", err)
+	}
+
+	// kitty is neither in the repos nor the AUR, so it never reaches a transaction
+	want := []string{"install git"}
+	if !reflect.DeepEqual(fake.Calls, want) {
+		t.Errorf("calls = %q, want %q", fake.Calls, want)
+	}
+	if got := fake.InstalledNames(); !reflect.DeepEqual(got, []string{"git"}) {
+		t.Errorf("installed = %q, want [git]", got)
+	}
+}
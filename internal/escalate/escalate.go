@@ -0,0 +1,177 @@
+// FILE: internal/escalate/escalate.go
+package escalate
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Backend is a privilege escalation command (sudo, doas or run0) and how
+// its flags translate.
+type Backend struct {
+	Name string
+
+	nonInteractive []string // fail instead of asking for a password
+	passwordStdin  []string // read the password from stdin and only authenticate, nil if unsupported
+	refresh        []string // extend cached credentials, nil if it has no cache to extend
+	invalidate     []string // drop cached credentials, nil if there are none
+	// envFlags passes variables to the command, nil means wrapping it in env(1)
+	envFlags func(env []string) []string
+
+	// NoPersistence explains why --root-setup can't keep credentials for this
+	// backend, empty if it can
+	NoPersistence string
+}
+
+var backends = []*Backend{
+	{
+		Name:           "sudo",
+		nonInteractive: []string{"-n"},
+		passwordStdin:  []string{"-S", "-v", "-p", ""},
+		refresh:        []string{"-n", "-v"},
+		invalidate:     []string{"-k"},
+	},
+	{
+		// OpenDoas caches credentials only with "persist" in doas.conf (5 minutes, not extendable)
+		Name:           "doas",
+		nonInteractive: []string{"-n"},
+		invalidate:     []string{"-L"},
+		NoPersistence: "doas has no drop-in directory for a temporary rule and its 'persist' cache can't be extended. " +
+			"guhwizard asks for the password once and keeps a privileged helper for the whole run instead.",
+	},
+	{
+		Name:           "run0",
+		nonInteractive: []string{"--no-ask-password"},
+		envFlags: func(env []string) []string {
+			flags := make([]string, len(env))
+			for i, kv := range env {
+				flags[i] = "--setenv=" + kv
+			}
+			return flags
+		},
+		NoPersistence: "run0 authenticates every command through polkit and keeps no credentials between them. " +
+			"guhwizard asks for the password once and keeps a privileged helper for the whole run instead.",
+	},
+}
+
+// selected is set by Select. Until then Current detects the backend.
+var selected *Backend
+
+// Names lists the supported backends, in detection order.
+func Names() []string {
+	names := make([]string, len(backends))
+	for i, b := range backends {
+		names[i] = b.Name
+	}
+	return names
+}
+
+// Lookup returns the backend called name.
+func Lookup(name string) (*Backend, error) {
+	for _, b := range backends {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown escalation backend %q (expected %s)", name, strings.Join(Names(), ", "))
+}
+
+// Detect returns the first backend installed on this system.
+func Detect() (*Backend, error) {
+	for _, b := range backends {
+		if _, err := exec.LookPath(b.Name); err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("none of %s is installed", strings.Join(Names(), ", "))
+}
+
+// Select picks the backend by name, or detects it when name is empty.
+func Select(name string) error {
+	var b *Backend
+	var err error
+	if name == "" {
+		b, err = Detect()
+	} else {
+		b, err = Lookup(name)
+		if err == nil {
+			if _, lookErr := exec.LookPath(name); lookErr != nil {
+				err = fmt.Errorf("%s is not installed", name)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	selected = b
+	return nil
+}
+
+// Current returns the selected backend, detecting it the first time.
+// Without any installed, it returns sudo so the error surfaces when it runs.
+func Current() *Backend {
+	if selected == nil {
+		if err := Select(""); err != nil {
+			selected = backends[0]
+		}
+	}
+	return selected
+}
+
+// Command builds `<backend> [flags] argv...`, see Args.
+func (b *Backend) Command(nonInteractive bool, env []string, argv ...string) *exec.Cmd {
+	return exec.Command(b.Name, b.Args(nonInteractive, env, argv...)...)
+}
+
+// Args are the arguments to the backend binary for running argv. env is
+// passed to the command explicitly, the backends all reset the environment.
+func (b *Backend) Args(nonInteractive bool, env []string, argv ...string) []string {
+	var args []string
+	if nonInteractive {
+		args = append(args, b.nonInteractive...)
+	}
+	if len(env) > 0 {
+		if b.envFlags != nil {
+			args = append(args, b.envFlags(env)...)
+		} else {
+			args = append(append(args, "env"), env...)
+		}
+	}
+	return append(args, argv...)
+}
+
+// Validate checks that commands run without asking for a password.
+func (b *Backend) Validate() error {
+	return b.Command(true, nil, "true").Run()
+}
+
+// AcceptsPassword reports whether the installer can pass the password itself
+// (AuthenticateCmd). The others ask on the terminal.
+func (b *Backend) AcceptsPassword() bool { return b.passwordStdin != nil }
+
+// AuthenticateCmd caches credentials from a password written to its stdin.
+func (b *Backend) AuthenticateCmd() *exec.Cmd {
+	return exec.Command(b.Name, b.passwordStdin...)
+}
+
+// NonInteractiveFlags are the backend's flags to fail instead of asking for a password.
+func (b *Backend) NonInteractiveFlags() []string {
+	return append([]string{}, b.nonInteractive...)
+}
+
+// RefreshCmd extends cached credentials, nil if the backend can't.
+func (b *Backend) RefreshCmd() *exec.Cmd {
+	if b.refresh == nil {
+		return nil
+	}
+	return exec.Command(b.Name, b.refresh...)
+}
+
+// InvalidateCmd drops cached credentials, nil if there are none.
+func (b *Backend) InvalidateCmd() *exec.Cmd {
+	if b.invalidate == nil {
+		return nil
+	}
+	return exec.Command(b.Name, b.invalidate...)
+}
@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"guhwizard/internal/target"
 )
 
 // CopyFile copies a file from src to dst.
@@ -100,14 +102,15 @@ func AtomicWrite(path string, content []byte, mode os.FileMode) error {
 	return os.Rename(tmpFile.Name(), path)
 }
 
-// ExpandHome expands the "~" in a path to the user's home directory.
+// ExpandHome expands the "~" in a path to the target user's home directory
+// (see target.Current, not necessarily the user running guhwizard).
 func ExpandHome(path string) (string, error) {
 	if strings.HasPrefix(path, "~/") || path == "~" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return "", err
+		home := target.Current().Home
+		if home == "" {
+			return "", fmt.Errorf("no home directory for %s", target.Current().Name)
 		}
-		return filepath.Join(home, path[2:]), nil
+		return filepath.Join(home, strings.TrimPrefix(path[1:], "/")), nil
 	}
 	return path, nil
 }
@@ -0,0 +1,178 @@
+// FILE: internal/fs/targetfs.go
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"guhwizard/internal/target"
+)
+
+// Flag (as --home-op) runs one of the Target operations, see RunTargetOp.
+const Flag = "home-op"
+
+// The Target functions change files in the target user's home as that user.
+// Running as root, guhwizard would otherwise follow symlinks the user planted
+// there (~/Downloads -> /etc) with root's rights, so they run in a child
+// dropped to the user (`guhwizard --home-op`, through target.Command).
+// Otherwise guhwizard is the user already and they run directly.
+
+// TargetRemoveAll removes path and what it contains, like os.RemoveAll.
+func TargetRemoveAll(path string) error {
+	_, err := asTarget(nil, "remove", path)
+	return err
+}
+
+// TargetMkdirAll creates path and its missing parents with mode, like os.MkdirAll.
+func TargetMkdirAll(path string, mode os.FileMode) error {
+	_, err := asTarget(nil, "mkdir", path, formatMode(mode))
+	return err
+}
+
+// TargetMkdirTemp creates parent (0700) if needed and a new directory in it,
+// like os.MkdirTemp, and returns its path.
+func TargetMkdirTemp(parent, pattern string) (string, error) {
+	return asTarget(nil, "mkdtemp", parent, pattern)
+}
+
+// TargetReadFile reads path, like os.ReadFile.
+func TargetReadFile(path string) ([]byte, error) {
+	out, err := asTarget(nil, "read", path)
+	return []byte(out), err
+}
+
+// TargetWriteFile replaces path with content, see AtomicWrite.
+func TargetWriteFile(path string, content []byte, mode os.FileMode) error {
+	_, err := asTarget(content, "write", path, formatMode(mode))
+	return err
+}
+
+// TargetCopyTree copies the files below src into dst, backing up the ones it
+// replaces (see BackupAndCopy), and returns their paths relative to src.
+func TargetCopyTree(src, dst string) ([]string, error) {
+	out, err := asTarget(nil, "copy-tree", src, dst)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(out, "\n"), "\n"), nil
+}
+
+// asTarget runs op with args, in a child as the target user when guhwizard
+// isn't them, and returns what it printed.
+func asTarget(input []byte, op string, args ...string) (string, error) {
+	if !target.Switching() {
+		var out bytes.Buffer
+		err := runOp(op, args, bytes.NewReader(input), &out)
+		return out.String(), err
+	}
+
+	// /proc/self/exe also works when the user can't reach guhwizard's directory
+	cmd := target.Command("/proc/self/exe", append([]string{"--" + Flag, op}, args...)...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s", msg)
+		}
+		return "", fmt.Errorf("%s as %s: %w", op, target.Current().Name, err)
+	}
+	return stdout.String(), nil
+}
+
+// RunTargetOp is the child side of the Target functions: op and its arguments,
+// the content to write on in, the result on out.
+func RunTargetOp(args []string, in io.Reader, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no operation")
+	}
+	return runOp(args[0], args[1:], in, out)
+}
+
+func runOp(op string, args []string, in io.Reader, out io.Writer) error {
+	want := map[string]int{"remove": 1, "mkdir": 2, "mkdtemp": 2, "read": 1, "write": 2, "copy-tree": 2}
+	if n, ok := want[op]; !ok || len(args) != n {
+		return fmt.Errorf("invalid operation %s %q", op, args)
+	}
+
+	switch op {
+	case "remove":
+		return os.RemoveAll(args[0])
+	case "mkdir":
+		mode, err := parseMode(args[1])
+		if err != nil {
+			return err
+		}
+		return os.MkdirAll(args[0], mode)
+	case "mkdtemp":
+		if err := os.MkdirAll(args[0], 0700); err != nil {
+			return err
+		}
+		dir, err := os.MkdirTemp(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(out, dir)
+		return err
+	case "read":
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(out, f)
+		return err
+	case "write":
+		mode, err := parseMode(args[1])
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		return AtomicWrite(args[0], content, mode)
+	default: // copy-tree
+		return copyTree(args[0], args[1], out)
+	}
+}
+
+// copyTree is the `cp -r src/. dst/` of the dotfiles, printing each file it copied.
+func copyTree(src, dst string, out io.Writer) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		targetPath := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(targetPath, 0755)
+		}
+		if err := BackupAndCopy(path, targetPath); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, rel)
+		return err
+	})
+}
+
+func formatMode(mode os.FileMode) string {
+	return strconv.FormatUint(uint64(mode.Perm()), 8)
+}
+
+func parseMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode %s", s)
+	}
+	return os.FileMode(mode).Perm(), nil
+}
@@ -0,0 +1,111 @@
+// FILE: internal/installer/audit.go
+package installer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"guhwizard/internal/audit"
+	"guhwizard/internal/config"
+	"guhwizard/internal/escalate"
+)
+
+// auditCause is the blueprint entry privileged operations are attributed to
+// in the audit log, e.g. "shell/zsh" or "hook post_install: enable services".
+var auditCause string
+
+// SetAuditCause attributes the privileged operations that follow to a
+// blueprint entry. The engine sets it as it works through the blueprint.
+func SetAuditCause(cause string) {
+	auditCause = cause
+}
+
+// withCause attributes what follows to cause until the returned func restores the previous one.
+func withCause(cause string) (restore func()) {
+	prev := auditCause
+	auditCause = cause
+	return func() { auditCause = prev }
+}
+
+// packageCause names the blueprint entries that asked for pkgs: "base_packages"
+// or STEP/ITEM, comma separated for a batch.
+func packageCause(cfg *config.Config, pkgs []string) string {
+	owners := make(map[string]string)
+	for _, name := range cfg.Settings.BasePackages {
+		owners[name] = "base_packages"
+	}
+	for _, step := range cfg.Steps {
+		for _, item := range step.Items {
+			if item.Selected && isPackageItem(step, item) {
+				if _, ok := owners[item.Name]; !ok {
+					owners[item.Name] = step.ID + "/" + item.Name
+				}
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var causes []string
+	for _, pkg := range pkgs {
+		cause := owners[pkg]
+		if cause != "" && !seen[cause] {
+			seen[cause] = true
+			causes = append(causes, cause)
+		}
+	}
+	return strings.Join(causes, ", ")
+}
+
+// currentUser is who ran guhwizard, for entries written outside the helper.
+// As root that's who started it through sudo or doas, if known.
+func currentUser() string {
+	if runningAsRoot() {
+		if name := audit.Invoker(); name != "" {
+			return name
+		}
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return fmt.Sprint(os.Getuid())
+}
+
+// recordPrivileged appends an entry for privileged work the helper didn't do
+// itself (it records its own): through the helper when it runs, otherwise
+// through `sudo guhwizard --audit-record`, or directly as root. A failure is
+// only a warning, the operation already happened.
+func recordPrivileged(e audit.Entry, log func(string)) {
+	var err error
+	switch {
+	case helper != nil:
+		err = helper.Record(e)
+	case runningAsRoot():
+		err = audit.Append(e)
+	default:
+		err = recordThroughBackend(e)
+	}
+	if err != nil {
+		log(fmt.Sprintf("Warning: could not write the audit log: %v\n", err))
+	}
+}
+
+func recordThroughBackend(e audit.Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := escalate.Current().Command(true, nil, exe, "--"+audit.Flag)
+	cmd.Stdin = bytes.NewReader(data)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
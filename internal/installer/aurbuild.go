@@ -0,0 +1,141 @@
+// FILE: internal/installer/aurbuild.go
+package installer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"guhwizard/internal/fs"
+	"guhwizard/internal/target"
+)
+
+// HelperBinary maps an AUR helper package (yay, paru-bin, yay-git...) to the command it installs.
+func HelperBinary(pkg string) string {
+	for _, suffix := range []string{"-bin", "-git"} {
+		pkg = strings.TrimSuffix(pkg, suffix)
+	}
+	return pkg
+}
+
+// newBuildDir creates a private (0700) build directory under the target user's cache dir.
+// Unlike ~/Downloads, nothing else lives there, so nothing of the user's gets wiped.
+// The user creates it (fs.TargetMkdirTemp), makepkg builds in it as them.
+// Remove it with fs.TargetRemoveAll.
+func newBuildDir(name string) (string, error) {
+	cache, err := target.Current().CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return fs.TargetMkdirTemp(filepath.Join(cache, "guhwizard", "build"), name+"-*")
+}
+
+// BuiltPackage is a package file produced by makepkg.
+type BuiltPackage struct {
+	Path    string
+	Name    string
+	Version string // pkgver-pkgrel, with epoch if any
+	Arch    string
+}
+
+// parsePackageFile splits "<name>-<pkgver>-<pkgrel>-<arch>.pkg.tar.<ext>".
+// Names may contain dashes, versions and arches can't, so it is parsed from the right.
+func parsePackageFile(path string) (BuiltPackage, bool) {
+	base := filepath.Base(path)
+	idx := strings.Index(base, ".pkg.tar")
+	if idx < 0 || strings.HasSuffix(base, ".sig") {
+		return BuiltPackage{}, false
+	}
+
+	parts := strings.Split(base[:idx], "-")
+	if len(parts) < 4 {
+		return BuiltPackage{}, false
+	}
+
+	n := len(parts)
+	return BuiltPackage{
+		Path:    path,
+		Name:    strings.Join(parts[:n-3], "-"),
+		Version: parts[n-3] + "-" + parts[n-2],
+		Arch:    parts[n-1],
+	}, true
+}
+
+// builtPackages returns the package files in dir for the wanted names,
+// skipping the -debug packages makepkg produces when debug is enabled.
+func builtPackages(dir string, wanted []string) ([]BuiltPackage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	want := make(map[string]bool)
+	for _, w := range wanted {
+		want[w] = true
+	}
+
+	var pkgs []BuiltPackage
+	for _, e := range entries {
+		pkg, ok := parsePackageFile(filepath.Join(dir, e.Name()))
+		if !ok || strings.HasSuffix(pkg.Name, "-debug") {
+			continue
+		}
+		if want[pkg.Name] {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("makepkg produced no package named %s in %s", strings.Join(wanted, ", "), dir)
+	}
+	return pkgs, nil
+}
+
+// checkoutCommit pins a cloned AUR repo to a specific commit.
+func checkoutCommit(repoDir, commit string) error {
+	cmd := target.Command("git", "-c", "advice.detachedHead=false", "checkout", "--detach", commit)
+	cmd.Dir = repoDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to check out %s: %s", commit, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// reviewPKGBUILD prints the PKGBUILD to the log and asks whether to build it.
+func reviewPKGBUILD(repoDir, name string, log func(string)) error {
+	data, err := os.ReadFile(filepath.Join(repoDir, "PKGBUILD"))
+	if err != nil {
+		return fmt.Errorf("failed to read PKGBUILD: %w", err)
+	}
+
+	log(fmt.Sprintf("----- PKGBUILD for %s -----\n", name))
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		log(line + "\n")
+	}
+	log("----- end of PKGBUILD -----\n")
+
+	answer, err := ask(fmt.Sprintf("Review the PKGBUILD for %s in the logs. Build and install it?", name), []string{"Build", "Abort"})
+	if err != nil {
+		return fmt.Errorf("PKGBUILD review for %s: %w", name, err)
+	}
+	if answer != "Build" {
+		return fmt.Errorf("build of %s aborted after PKGBUILD review", name)
+	}
+	return nil
+}
+
+// makepkg builds repoDir into pkgDir, as the target user.
+func makepkg(repoDir, pkgDir string, log func(string), extraArgs ...string) error {
+	if err := fs.TargetMkdirAll(pkgDir, 0700); err != nil {
+		return err
+	}
+
+	args := append([]string{"-f", "--noconfirm"}, extraArgs...)
+	cmd := exec.Command("makepkg", args...)
+	cmd.Dir = repoDir
+	// Honour the build dir even if the user set PKGDEST in makepkg.conf
+	cmd.Env = append(os.Environ(), "PKGDEST="+pkgDir)
+	return runLogged(target.Adopt(cmd), log)
+}
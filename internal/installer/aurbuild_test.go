@@ -0,0 +1,45 @@
+// FILE: internal/installer/aurbuild_test.go
+package installer
+
+import "testing"
+
+func TestParsePackageFile(t *testing.T) {
+	tests := []struct {
+		path string
+		want BuiltPackage
+		ok   bool
+	}{
+		{
+			path: "/tmp/build/yay-12.4.2-1-x86_64.pkg.tar.zst",
+			want: BuiltPackage{Path: "/tmp/build/yay-12.4.2-1-x86_64.pkg.tar.zst", Name: "yay", Version: "12.4.2-1", Arch: "x86_64"},
+			ok:   true,
+		},
+		{
+			path: "paru-bin-debug-2.0.4-1-x86_64.pkg.tar.zst",
+			want: BuiltPackage{Path: "paru-bin-debug-2.0.4-1-x86_64.pkg.tar.zst", Name: "paru-bin-debug", Version: "2.0.4-1", Arch: "x86_64"},
+			ok:   true,
+		},
+		{
+			path: "ttf-font-awesome-1:6.7.2-1-any.pkg.tar.xz",
+			want: BuiltPackage{Path: "ttf-font-awesome-1:6.7.2-1-any.pkg.tar.xz", Name: "ttf-font-awesome", Version: "1:6.7.2-1", Arch: "any"},
+			ok:   true,
+		},
+		{path: "yay-12.4.2-1-x86_64.pkg.tar.zst.sig"},
+		{path: "yay-12.4.2-1.tar.gz"},
+		{path: "yay-x86_64.pkg.tar.zst"},
+	}
+	for _, tt := range tests {
+		got, ok := parsePackageFile(tt.path)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parsePackageFile(%q) = %+v, %v, want %+v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHelperBinary(t *testing.T) {
+	for pkg, want := range map[string]string{"yay": "yay", "yay-bin": "yay", "paru-git": "paru", "paru-bin": "paru"} {
+		if got := HelperBinary(pkg); got != want {
+			t.Errorf("HelperBinary(%q) = %q, want %q", pkg, got, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// FILE: internal/installer/aurhelper.go
+package installer
+
+import (
+	"path/filepath"
+	"strings"
+
+	"guhwizard/internal/audit"
+	"guhwizard/internal/escalate"
+	"guhwizard/internal/target"
+)
+
+// AURHelper drives yay or paru. They run as the user and call sudo themselves
+// when they need to install what they built, on the cached credentials. Those
+// pacman commands don't go through the privileged helper, so its allow-list
+// doesn't apply and the audit log only has the whole yay or paru run (see run).
+type AURHelper struct {
+	bin          string
+	installFlags []string
+	// reviewFlags skip the questions --noconfirm would, for installs that have to go without it
+	reviewFlags []string
+	cloneDir    string // under the user cache dir, where the helper keeps its AUR clones
+}
+
+// NewYay returns a yay backend.
+func NewYay() *AURHelper {
+	return &AURHelper{
+		bin:          "yay",
+		installFlags: []string{"-S", "--noconfirm", "--needed"},
+		reviewFlags:  []string{"--answerclean", "None", "--answerdiff", "None", "--answeredit", "None"},
+		cloneDir:     "yay",
+	}
+}
+
+// NewParu returns a paru backend. paru shows PKGBUILDs for review unless told not to.
+func NewParu() *AURHelper {
+	return &AURHelper{
+		bin:          "paru",
+		installFlags: []string{"-S", "--noconfirm", "--needed", "--skipreview"},
+		cloneDir:     filepath.Join("paru", "clone"),
+	}
+}
+
+func (h *AURHelper) Name() string { return h.bin }
+
+func (h *AURHelper) Install(pkgs []string, log func(string)) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	flags := h.installFlags
+	if replacing(pkgs) {
+		// pacman, which they call, has to ask to remove what pkgs replace
+		// (see confirmReplacements), their own questions are skipped instead
+		flags = append(withoutNoConfirm(flags), h.reviewFlags...)
+	}
+	args := append(append([]string{}, flags...), escalationFlags()...)
+	args = append(args, pkgs...)
+	return h.run(log, args...)
+}
+
+func (h *AURHelper) IsInstalled(pkg string) (bool, error) {
+	return queryInstalled(h.bin, pkg)
+}
+
+func (h *AURHelper) Info(pkg string) (*PackageInfo, error) {
+	return queryInfo(h.bin, pkg)
+}
+
+func (h *AURHelper) Remove(pkgs []string, log func(string)) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	args := append(append([]string{"-Rns", "--noconfirm"}, escalationFlags()...), pkgs...)
+	return h.run(log, args...)
+}
+
+func (h *AURHelper) Refresh(log func(string)) error {
+	return h.run(log, append([]string{"-Sy", "--noconfirm"}, escalationFlags()...)...)
+}
+
+// run runs yay or paru as the user and records the run in the audit log,
+// attributed to the escalation backend it calls. What it did as root is only
+// in its own output.
+func (h *AURHelper) run(log func(string), args ...string) error {
+	cmd := target.Command(h.bin, args...)
+	entry := audit.Begin("exec", cmd.Args, auditCause, currentUser(), escalate.Current().Name+" from "+h.bin)
+	err := runOnTerminal(cmd, log)
+	entry.Finish(err)
+	recordPrivileged(entry, log)
+	return err
+}
+
+// Commit reads the commit of the helper's cached clone of pkg. Clones are
+// named after the pkgbase, so split packages come back empty.
+func (h *AURHelper) Commit(pkg string) string {
+	cache, err := target.Current().CacheDir()
+	if err != nil {
+		return ""
+	}
+	return headCommit(filepath.Join(cache, h.cloneDir, pkg))
+}
+
+// escalationFlags have the sudo that yay and paru call fail rather than ask
+// for a password (see runOnTerminal). They only run with sudo, the other
+// backends get the built-in builder (AURHelperOverride).
+func escalationFlags() []string {
+	return []string{"--sudoflags", strings.Join(escalate.Current().NonInteractiveFlags(), " ")}
+}
@@ -0,0 +1,83 @@
+// FILE: internal/installer/bisect.go
+package installer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How many trailing output lines to keep for a failed package.
+const excerptLines = 15
+
+// PackageError is a package that could not be installed, with the tail of its output.
+type PackageError struct {
+	Package string
+	Err     error
+	Excerpt []string
+}
+
+func (e *PackageError) Error() string { return e.Err.Error() }
+func (e *PackageError) Unwrap() error { return e.Err }
+
+// tailLog forwards to log while remembering the last few lines.
+type tailLog struct {
+	log   func(string)
+	lines []string
+}
+
+func (t *tailLog) Log(msg string) {
+	t.log(msg)
+	for _, line := range strings.Split(strings.TrimRight(msg, "\n"), "\n") {
+		t.lines = append(t.lines, line)
+	}
+	if len(t.lines) > excerptLines {
+		t.lines = t.lines[len(t.lines)-excerptLines:]
+	}
+}
+
+// installOne installs a single package and records a PackageError on failure.
+func installOne(pm PackageManager, pkg string, log func(string), failed map[string]error) bool {
+	tail := &tailLog{log: log}
+	if err := pm.Install([]string{pkg}, tail.Log); err != nil {
+		failed[pkg] = &PackageError{Package: pkg, Err: err, Excerpt: tail.lines}
+		return false
+	}
+	return true
+}
+
+// bisectInstall installs pkgs as one batch. If the batch fails it is split in
+// halves recursively, so everything installable gets installed and only the
+// packages that break the transaction end up in failed.
+func bisectInstall(pm PackageManager, pkgs []string, log func(string), failed map[string]error) {
+	if len(pkgs) == 1 {
+		installOne(pm, pkgs[0], log, failed)
+		return
+	}
+
+	err := pm.Install(pkgs, log)
+	if err == nil {
+		return
+	}
+	log(fmt.Sprintf("Batch of %d packages failed (%v), splitting to find the culprit...\n", len(pkgs), err))
+
+	mid := len(pkgs) / 2
+	bisectInstall(pm, pkgs[:mid], log, failed)
+	bisectInstall(pm, pkgs[mid:], log, failed)
+}
+
+// reportFailures logs each failed package with its output excerpt.
+func reportFailures(failed map[string]error, order []string, log func(string)) {
+	log(fmt.Sprintf("%d packages could not be installed:\n", len(failed)))
+	for _, pkg := range order {
+		err, ok := failed[pkg]
+		if !ok {
+			continue
+		}
+		log(fmt.Sprintf("  %s: %v\n", pkg, err))
+		if pe, ok := err.(*PackageError); ok {
+			for _, line := range pe.Excerpt {
+				log(fmt.Sprintf("    | %s\n", line))
+			}
+		}
+	}
+}
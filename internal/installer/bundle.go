@@ -0,0 +1,293 @@
+// FILE: internal/installer/bundle.go
+package installer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/fs"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scriptURL finds the download in commands like "curl -fsSL https://.../install.sh | bash".
+var scriptURL = regexp.MustCompile(`https?://[^\s|;&'"]+`)
+
+// CreateBundle downloads everything the blueprint could need into dir: repo
+// packages with their full dependency closure, AUR packages and helpers built
+// with the native builder, git repos as git bundles and external scripts.
+// The result is a pacman repo that EnableOffline can install from.
+func CreateBundle(cfg *config.Config, dir string, log func(string)) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	repoDir := filepath.Join(dir, bundleRepoDir)
+	for _, d := range []string{repoDir, filepath.Join(dir, bundleGitDir), filepath.Join(dir, bundleScriptDir)} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return err
+		}
+	}
+
+	// 1. Work out what comes from where
+	pkgs := append(BlueprintPackages(cfg), "git", "base-devel")
+	pkgs = append(pkgs, sddmDeps...)
+	pkgs = append(pkgs, aurHelperChoices(cfg)...)
+
+	log("Resolving packages...\n")
+	idx, err := OnlineIndex()
+	if err != nil {
+		return err
+	}
+
+	var repoPkgs, notRepo []string
+	for _, p := range pkgs {
+		if idx.Repo[p] {
+			repoPkgs = append(repoPkgs, p)
+		} else {
+			notRepo = append(notRepo, p)
+		}
+	}
+	inAUR, err := idx.AUR(notRepo)
+	if err != nil {
+		return fmt.Errorf("AUR lookup failed: %w", err)
+	}
+
+	failed := make(map[string]error)
+	var failedOrder []string
+	fail := func(what string, err error) {
+		log(fmt.Sprintf("Failed: %s: %v\n", what, err))
+		failed[what] = err
+		failedOrder = append(failedOrder, what)
+	}
+
+	// 2. Build AUR packages one by one, collecting their repo dependencies
+	native := NewNativeAUR(cfg.Settings.AURURL)
+	for _, p := range notRepo {
+		if !inAUR[p] {
+			fail(p, fmt.Errorf("not found in the repos or the AUR"))
+			continue
+		}
+		log(fmt.Sprintf("Building %s for the bundle...\n", p))
+		deps, err := native.Build([]string{p}, repoDir, log)
+		if err != nil {
+			fail(p, err)
+			continue
+		}
+		repoPkgs = append(repoPkgs, deps...)
+	}
+
+	// 3. Download repo packages with everything they depend on
+	if err := downloadPackages(repoPkgs, repoDir, log); err != nil {
+		return err
+	}
+
+	if err := buildRepoDB(repoDir, log); err != nil {
+		return err
+	}
+
+	manifest := BundleManifest{Git: make(map[string]string), Scripts: make(map[string]string)}
+
+	// 4. Git repos used at install time
+	for _, url := range []string{cfg.Settings.Dotfiles.Repo, SilentSDDMRepo} {
+		if url == "" {
+			continue
+		}
+		log(fmt.Sprintf("Bundling %s...\n", url))
+		rel := filepath.Join(bundleGitDir, bundleFileName(url)+".bundle")
+		if err := gitBundle(url, filepath.Join(dir, rel)); err != nil {
+			fail(url, err)
+			continue
+		}
+		manifest.Git[url] = rel
+	}
+
+	// 5. External scripts that are fetched from a URL
+	for _, script := range cfg.Settings.ExternalScripts {
+		url := scriptURL.FindString(script.Command)
+		if url == "" {
+			continue
+		}
+		log(fmt.Sprintf("Downloading script %s...\n", script.Name))
+		rel := filepath.Join(bundleScriptDir, bundleFileName(script.Name)+".sh")
+		if err := download(url, filepath.Join(dir, rel)); err != nil {
+			fail(script.Name, err)
+			continue
+		}
+		manifest.Scripts[script.Name] = rel
+	}
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, bundleManifest), data, 0644); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("bundle is incomplete, %d items failed: %s", len(failed), formatFailures(failed, failedOrder))
+	}
+	log(fmt.Sprintf("Bundle ready in %s\n", dir))
+	return nil
+}
+
+// aurHelperChoices returns the helpers offered in the aur step, so any of them works offline.
+func aurHelperChoices(cfg *config.Config) []string {
+	var helpers []string
+	for _, step := range cfg.Steps {
+		if step.ID != "aur" {
+			continue
+		}
+		for _, item := range step.Items {
+			if item.Name != NativeHelper {
+				helpers = append(helpers, item.Name)
+			}
+		}
+	}
+	return helpers
+}
+
+// downloadPackages fetches pkgs and their whole dependency closure into dest.
+// An empty temporary database makes pacman treat nothing as already installed.
+// Through the privileged helper, pacman downloads into its workspace and the
+// files are copied out as the user.
+func downloadPackages(pkgs []string, dest string, log func(string)) error {
+	if helper == nil {
+		return downloadPackagesDirect(pkgs, dest, log)
+	}
+	dbPath, err := helper.TempDir("dbpath")
+	if err != nil {
+		return err
+	}
+	defer helper.Release(dbPath)
+	cacheDir, err := helper.TempDir("cachedir")
+	if err != nil {
+		return err
+	}
+	defer helper.Release(cacheDir)
+
+	log(fmt.Sprintf("Downloading %d repo packages and their dependencies...\n", len(pkgs)))
+	args := append([]string{"-Syw", "--noconfirm", "--dbpath", dbPath, "--cachedir", cacheDir}, pkgs...)
+	if err := RunSudo(log, "pacman", args...); err != nil {
+		return fmt.Errorf("failed to download packages: %w", err)
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err := fs.CopyFile(filepath.Join(cacheDir, entry.Name()), filepath.Join(dest, entry.Name())); err != nil {
+			return fmt.Errorf("cannot copy %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// downloadPackagesDirect is downloadPackages through the escalation backend
+// (or as root): pacman writes into dest, which is handed back to the user.
+func downloadPackagesDirect(pkgs []string, dest string, log func(string)) error {
+	dbPath, err := os.MkdirTemp("", "guhwizard-db-*")
+	if err != nil {
+		return err
+	}
+	defer RunSudo(log, "rm", "-rf", dbPath)
+	if err := os.Mkdir(filepath.Join(dbPath, "local"), 0755); err != nil {
+		return err
+	}
+
+	log(fmt.Sprintf("Downloading %d repo packages and their dependencies...\n", len(pkgs)))
+	args := append([]string{"-Syw", "--noconfirm", "--dbpath", dbPath, "--cachedir", dest}, pkgs...)
+	if err := RunSudo(log, "pacman", args...); err != nil {
+		return fmt.Errorf("failed to download packages: %w", err)
+	}
+
+	// pacman wrote the files as root, hand them back so repo-add can run as the user
+	owner := fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+	return RunSudo(log, "chown", "-R", owner, dest)
+}
+
+// buildRepoDB indexes every package file in repoDir with repo-add.
+func buildRepoDB(repoDir string, log func(string)) error {
+	entries, err := os.ReadDir(repoDir)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	for _, e := range entries {
+		if _, ok := parsePackageFile(e.Name()); ok {
+			files = append(files, filepath.Join(repoDir, e.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no packages in %s", repoDir)
+	}
+
+	log(fmt.Sprintf("Indexing %d packages...\n", len(files)))
+	db := filepath.Join(repoDir, OfflineRepoName+".db.tar.gz")
+	args := append([]string{"--quiet", "--remove", db}, files...)
+	return runLogged(exec.Command("repo-add", args...), log)
+}
+
+// gitBundle mirrors url and packs every ref into a single bundle file.
+func gitBundle(url, dest string) error {
+	tmp, err := os.MkdirTemp("", "guhwizard-mirror-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if out, err := exec.Command("git", "clone", "--mirror", url, tmp).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	if out, err := exec.Command("git", "-C", tmp, "bundle", "create", dest, "--all").CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	return nil
+}
+
+func download(url, dest string) error {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// bundleFileName turns a URL or name into something safe to use as a file name.
+func bundleFileName(s string) string {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "https://"), "http://")
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSuffix(s, ".git"))
+}
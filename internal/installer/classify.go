@@ -0,0 +1,109 @@
+// FILE: internal/installer/classify.go
+package installer
+
+import (
+	"fmt"
+	"strings"
+
+	"guhwizard/internal/config"
+)
+
+// PackageSource is where a package will be installed from.
+type PackageSource int
+
+const (
+	SourceUnknown PackageSource = iota
+	SourceRepo
+	SourceAUR
+)
+
+func (s PackageSource) String() string {
+	switch s {
+	case SourceRepo:
+		return "repo"
+	case SourceAUR:
+		return "aur"
+	}
+	return "unknown"
+}
+
+// RepoManager is a PackageManager backed by pacman sync databases.
+type RepoManager interface {
+	PackageManager
+	// SyncPackages returns every package (and group) name in the sync databases.
+	SyncPackages() (map[string]bool, error)
+}
+
+// Classification groups packages by source, keeping their original order.
+type Classification struct {
+	Repo    []string
+	AUR     []string
+	Unknown []string
+}
+
+// ClassifyPackages sorts pkgs into repo, AUR and unknown.
+// Anything in the sync databases is a repo package. The rest is looked up
+// through the AUR backend, which reports AUR packages with Repository "aur".
+func ClassifyPackages(pkgs []string, repo RepoManager, aur PackageManager) (*Classification, error) {
+	synced, err := repo.SyncPackages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync databases: %w", err)
+	}
+
+	c := &Classification{}
+	for _, pkg := range pkgs {
+		if synced[pkg] {
+			c.Repo = append(c.Repo, pkg)
+			continue
+		}
+		if info, err := aur.Info(pkg); err == nil && info.Repository == "aur" {
+			c.AUR = append(c.AUR, pkg)
+			continue
+		}
+		c.Unknown = append(c.Unknown, pkg)
+	}
+	return c, nil
+}
+
+// SelectedPackages returns the base packages plus every selected item, without duplicates.
+func SelectedPackages(cfg *config.Config) []string {
+	seen := make(map[string]bool)
+	var pkgs []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			pkgs = append(pkgs, name)
+		}
+	}
+
+	for _, name := range cfg.Settings.BasePackages {
+		add(name)
+	}
+	for _, step := range cfg.Steps {
+		for _, item := range step.Items {
+			if item.Selected && isPackageItem(step, item) {
+				add(item.Name)
+			}
+		}
+	}
+	return pkgs
+}
+
+// isPackageItem reports whether selecting item means installing a package of that name.
+// "None" is the "don't install anything" choice of single-select steps, the
+// aur step picks the helper, which InstallAURHelper takes care of, and system
+// steps toggle settings.
+func isPackageItem(step config.Step, item config.Item) bool {
+	return item.Name != "None" && step.ID != "aur" && !step.System
+}
+
+// formatFailures renders a per-package failure map for the final error.
+func formatFailures(failed map[string]error, order []string) string {
+	var parts []string
+	for _, pkg := range order {
+		if err, ok := failed[pkg]; ok {
+			parts = append(parts, fmt.Sprintf("%s (%v)", pkg, err))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,239 @@
+// FILE: internal/installer/conflicts.go
+package installer
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"guhwizard/internal/config"
+)
+
+// ConflictChoice is how the user resolved a conflict.
+type ConflictChoice int
+
+const (
+	ConflictReplace ConflictChoice = iota // remove the installed package
+	ConflictKeep                          // keep the installed package, don't install the new one
+	ConflictSkip                          // deselect the item that wanted the new package
+)
+
+// ConflictChoices are the labels shown for each choice, in order.
+var ConflictChoices = []string{"Replace", "Keep existing", "Skip item"}
+
+// Conflict is a package to install that conflicts with (or replaces) an installed one.
+type Conflict struct {
+	Package   string
+	Installed string
+	Replaces  bool // Package lists Installed in replaces=, not only conflicts=
+	// Step and Item are where Package was selected. Empty for base packages,
+	// which can't be skipped.
+	Step   string
+	Item   string
+	Choice ConflictChoice
+}
+
+// conflictPlan holds the resolutions chosen before the install, see ResolveConflicts.
+var conflictPlan struct {
+	keep    map[string]bool     // packages not to install
+	replace map[string][]string // package -> the installed packages it replaces
+}
+
+var (
+	// pacman asking whether to remove an installed package in the way:
+	// ":: foo and bar are in conflict (baz). Remove bar? [y/N]"
+	conflictPrompt = regexp.MustCompile(`^:: (\S+) and (\S+) are in conflict(?: \(.+\))?\. Remove (\S+)\? \[y/N\]$`)
+	// its final confirmation, asked in replacing transactions (see confirmReplacements)
+	proceedPrompt = regexp.MustCompile(`^:: Proceed with installation\? \[Y/n\]$`)
+)
+
+// DetectConflicts inspects the metadata of every package the install would add
+// and reports those that conflict with or replace an installed package, matched
+// by name or by what the installed package provides. Packages whose metadata
+// can't be read (e.g. the AUR helper isn't installed yet) are not checked.
+func DetectConflicts(cfg *config.Config, repo PackageManager, aur PackageManager) ([]Conflict, error) {
+	installed, provides, err := localProvides()
+	if err != nil {
+		return nil, err
+	}
+
+	owner := make(map[string][2]string) // package -> step, item
+	for _, step := range cfg.Steps {
+		for _, item := range step.Items {
+			if item.Selected && isPackageItem(step, item) {
+				owner[item.Name] = [2]string{step.ID, item.Name}
+			}
+		}
+	}
+
+	var conflicts []Conflict
+	for _, pkg := range SelectedPackages(cfg) {
+		if installed[pkg] {
+			continue
+		}
+		info, err := repo.Info(pkg)
+		if err != nil {
+			if info, err = aur.Info(pkg); err != nil {
+				continue
+			}
+		}
+
+		seen := make(map[string]bool)
+		check := func(deps []string, replaces bool) {
+			for _, dep := range deps {
+				name := depName(dep)
+				victim := name
+				if !installed[victim] {
+					victim = provides[name]
+				}
+				if victim == "" || victim == pkg || seen[victim] {
+					continue
+				}
+				seen[victim] = true
+				// Declared replacements are meant to take over, plain conflicts default to keeping what is there
+				choice := ConflictKeep
+				if replaces {
+					choice = ConflictReplace
+				}
+				o := owner[pkg]
+				conflicts = append(conflicts, Conflict{Package: pkg, Installed: victim, Replaces: replaces, Step: o[0], Item: o[1], Choice: choice})
+			}
+		}
+		check(info.Replaces, true)
+		check(info.Conflicts, false)
+	}
+	return conflicts, nil
+}
+
+// ResolveConflicts applies the user's choices: skipped items are deselected in
+// cfg, kept packages are left out of InstallPackages, and the installed
+// packages to replace are removed by the transaction installing their
+// replacement, which asks pacman's conflict question (plannedAnswer answers it).
+// Conflicts the user wasn't asked about still fail the transaction.
+func ResolveConflicts(cfg *config.Config, conflicts []Conflict) {
+	conflictPlan.keep = make(map[string]bool)
+	conflictPlan.replace = make(map[string][]string)
+
+	for _, c := range conflicts {
+		switch c.Choice {
+		case ConflictReplace:
+			conflictPlan.replace[c.Package] = append(conflictPlan.replace[c.Package], c.Installed)
+		case ConflictKeep:
+			conflictPlan.keep[c.Package] = true
+		case ConflictSkip:
+			if c.Item == "" {
+				conflictPlan.keep[c.Package] = true
+				continue
+			}
+			for i := range cfg.Steps {
+				if cfg.Steps[i].ID != c.Step {
+					continue
+				}
+				for j := range cfg.Steps[i].Items {
+					if cfg.Steps[i].Items[j].Name == c.Item {
+						cfg.Steps[i].Items[j].Selected = false
+					}
+				}
+			}
+		}
+	}
+}
+
+// replacing reports whether one of pkgs replaces an installed package the
+// user chose to replace.
+func replacing(pkgs []string) bool {
+	for _, pkg := range pkgs {
+		if len(conflictPlan.replace[pkg]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmReplacements drops --noconfirm from a pacman install whose targets
+// replace installed packages: with it pacman answers its conflict question
+// with the default, no, and fails. Asked, the removal and the install are
+// one transaction, if it fails the replaced package stays.
+func confirmReplacements(args []string) []string {
+	var targets []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if pkg, ok := parsePackageFile(arg); ok {
+			arg = pkg.Name
+		}
+		targets = append(targets, arg)
+	}
+	if !replacing(targets) {
+		return args
+	}
+	return withoutNoConfirm(args)
+}
+
+func withoutNoConfirm(args []string) []string {
+	return slices.DeleteFunc(slices.Clone(args), func(arg string) bool { return arg == "--noconfirm" })
+}
+
+// plannedAnswer answers pacman's questions in a replacing transaction: yes
+// to removing the packages the user chose to replace, no to any other
+// conflict, and yes to going ahead. ok is false for every other question.
+func plannedAnswer(lines []string) (answer string, ok bool) {
+	if len(lines) == 0 {
+		return "", false
+	}
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if proceedPrompt.MatchString(last) {
+		return "y", true
+	}
+	m := conflictPrompt.FindStringSubmatch(last)
+	if m == nil {
+		return "", false
+	}
+	pkg, victim := m[1], m[3]
+	if pkg == victim {
+		pkg = m[2]
+	}
+	if slices.Contains(conflictPlan.replace[pkg], victim) {
+		return "y", true
+	}
+	return "n", true
+}
+
+// withoutKept drops the packages the user chose to keep the installed variant of.
+func withoutKept(pkgs []string, log func(string)) []string {
+	if len(conflictPlan.keep) == 0 {
+		return pkgs
+	}
+	return slices.DeleteFunc(pkgs, func(pkg string) bool {
+		if conflictPlan.keep[pkg] {
+			log(fmt.Sprintf("Keeping the installed package instead of %s.\n", pkg))
+			return true
+		}
+		return false
+	})
+}
+
+// localProvides lists installed packages and maps everything they provide to them.
+func localProvides() (map[string]bool, map[string]string, error) {
+	out, err := pacmanQuery("-Qi").Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read installed packages: %w", err)
+	}
+
+	installed := make(map[string]bool)
+	provides := make(map[string]string)
+	for _, block := range bytes.Split(out, []byte("\n\n")) {
+		info, err := parseInfo(block)
+		if err != nil || info.Name == "" {
+			continue
+		}
+		installed[info.Name] = true
+		for _, p := range info.Provides {
+			provides[depName(p)] = info.Name
+		}
+	}
+	return installed, provides, nil
+}
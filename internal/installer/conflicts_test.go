@@ -0,0 +1,50 @@
+// FILE: internal/installer/conflicts_test.go
+package installer
+
+import (
+	"reflect"
+	"testing"
+
+	"guhwizard/internal/config"
+)
+
+func TestReplacementTransaction(t *testing.T) {
+	ResolveConflicts(&config.Config{}, []Conflict{
+		{Package: "pipewire-jack", Installed: "jack2", Choice: ConflictReplace},
+		{Package: "vim", Installed: "gvim", Choice: ConflictKeep},
+	})
+	defer ResolveConflicts(&config.Config{}, nil)
+
+	args := []string{"-S", "--needed", "--noconfirm", "pipewire-jack", "git"}
+	if got, want := confirmReplacements(args), []string{"-S", "--needed", "pipewire-jack", "git"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("confirmReplacements(%q) = %q, want %q", args, got, want)
+	}
+	files := []string{"-U", "--noconfirm", "/tmp/pipewire-jack-1:1.2.7-1-x86_64.pkg.tar.zst"}
+	if got := confirmReplacements(files); len(got) != 2 {
+		t.Errorf("confirmReplacements(%q) = %q, want --noconfirm dropped", files, got)
+	}
+	plain := []string{"-S", "--needed", "--noconfirm", "git"}
+	if got := confirmReplacements(plain); !reflect.DeepEqual(got, plain) {
+		t.Errorf("confirmReplacements(%q) = %q, want it unchanged", plain, got)
+	}
+
+	tests := []struct {
+		line   string
+		answer string
+		ok     bool
+	}{
+		{":: pipewire-jack and jack2 are in conflict (jack). Remove jack2? [y/N] ", "y", true},
+		{":: pipewire-jack and jack2 are in conflict. Remove jack2? [y/N]", "y", true},
+		{":: vim and gvim are in conflict. Remove gvim? [y/N]", "n", true},
+		{":: pipewire-jack and jack-example-tools are in conflict. Remove jack-example-tools? [y/N]", "n", true},
+		{":: Proceed with installation? [Y/n] ", "y", true},
+		{":: Import PGP key 0123ABCD? [Y/n]", "", false},
+		{"Enter a number (default=1):", "", false},
+	}
+	for _, tt := range tests {
+		answer, ok := plannedAnswer([]string{"resolving dependencies...", tt.line})
+		if answer != tt.answer || ok != tt.ok {
+			t.Errorf("plannedAnswer(%q) = %q, %v, want %q, %v", tt.line, answer, ok, tt.answer, tt.ok)
+		}
+	}
+}
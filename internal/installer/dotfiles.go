@@ -3,12 +3,11 @@ package installer
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
 	"path/filepath"
 
 	"guhwizard/internal/config"
 	"guhwizard/internal/fs"
+	"guhwizard/internal/target"
 )
 
 func ProcessDotfiles(cfg *config.Config, log func(string)) error {
@@ -20,17 +19,18 @@ func ProcessDotfiles(cfg *config.Config, log func(string)) error {
 		return nil
 	}
 
-	home, _ := os.UserHomeDir()
-	tempDir := filepath.Join(home, "guhwm-temp")
+	tempDir := filepath.Join(target.Current().Home, "guhwm-temp")
 
-	// Clean previous run
-	os.RemoveAll(tempDir)
+	// Clean previous run. Everything in the home happens as the user, see fs.TargetRemoveAll
+	if err := fs.TargetRemoveAll(tempDir); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", tempDir, err)
+	}
 
 	log(fmt.Sprintf("Cloning %s...\n", repo))
-	if err := exec.Command("git", "clone", repo, tempDir).Run(); err != nil {
+	if err := gitClone(repo, tempDir); err != nil {
 		return fmt.Errorf("failed to clone dotfiles: %w", err)
 	}
-	defer os.RemoveAll(tempDir) // Cleanup
+	defer fs.TargetRemoveAll(tempDir) // Cleanup
 
 	// Process items
 	for _, item := range cfg.Settings.Dotfiles.Items {
@@ -44,36 +44,11 @@ func ProcessDotfiles(cfg *config.Config, log func(string)) error {
 
 		log(fmt.Sprintf("Installing configs to %s...\n", destPath))
 
-		// We need to copy contents of src to dest
-		// If src is a dir, we copy recursively?
 		// The original logic was `cp -r src/. dest/`
-		// We'll walk the source directory structure
-
-		err = filepath.Walk(fullSrc, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-
-			// Get relative path from source root
-			relPath, err := filepath.Rel(fullSrc, path)
-			if err != nil {
-				return err
-			}
-
-			if relPath == "." {
-				return nil
-			}
-
-			targetPath := filepath.Join(destPath, relPath)
-
-			if info.IsDir() {
-				return os.MkdirAll(targetPath, 0755)
-			}
-
+		copied, err := fs.TargetCopyTree(fullSrc, destPath)
+		for _, relPath := range copied {
 			log(fmt.Sprintf("  -> %s\n", relPath))
-			return fs.BackupAndCopy(path, targetPath)
-		})
-
+		}
 		if err != nil {
 			return fmt.Errorf("failed to copy configs: %w", err)
 		}
@@ -86,7 +61,7 @@ func RunExternalScripts(cfg *config.Config, log func(string)) error {
 	for _, script := range cfg.Settings.ExternalScripts {
 		log(fmt.Sprintf("Running script: %s\n", script.Name))
 
-		cmd := exec.Command("bash", "-c", script.Command)
+		cmd := target.Command("bash", "-c", scriptCommand(script))
 		out, err := cmd.CombinedOutput()
 		log(string(out))
 		if err != nil {
@@ -0,0 +1,171 @@
+// FILE: internal/installer/exec.go
+package installer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"guhwizard/internal/privileged"
+	"guhwizard/internal/pty"
+)
+
+// pipeWaitDelay bounds reading a piped command's output after it exited.
+const pipeWaitDelay = 500 * time.Millisecond
+
+// runContext cancels the commands runLogged runs, see SetContext.
+var runContext = context.Background()
+
+// SetContext makes cancelling ctx stop the command running at the time, and
+// every one after it. The engine sets it for the duration of an install.
+func SetContext(ctx context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	runContext = ctx
+}
+
+// Context returns the context SetContext set.
+func Context() context.Context {
+	return runContext
+}
+
+// LiveOutput receives the lines a running command is still redrawing (progress
+// bars, the line being printed), nil once they are committed to the log.
+// The engine points it at the TUI for the duration of an install.
+var LiveOutput func(lines []string)
+
+func liveOutput(lines []string) {
+	if LiveOutput != nil {
+		LiveOutput(lines)
+	}
+}
+
+// newScreen interprets terminal output for log and LiveOutput.
+func newScreen(log func(string)) *pty.Screen {
+	return pty.NewScreen(func(line string) { log(line + "\n") }, liveOutput)
+}
+
+// runLogged runs cmd on a pseudo-terminal, so progress bars and colors are
+// kept, and streams its output to log line by line. Questions it asks there
+// go to the user (watchChild). Without a terminal it falls back to a pipe.
+// The reader is joined before returning so no trailing output is lost.
+// Cancelling the install (SetContext) stops it, see runLoggedContext.
+func runLogged(cmd *exec.Cmd, log func(string)) error {
+	return runLoggedContext(runContext, cmd, log)
+}
+
+// runLoggedContext is runLogged stopped by ctx, see pty.StopOnCancel.
+func runLoggedContext(ctx context.Context, cmd *exec.Cmd, log func(string)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	master, err := pty.Start(cmd)
+	if errors.Is(err, pty.ErrUnavailable) {
+		return runPiped(ctx, cmd, log)
+	}
+	if err != nil {
+		return err
+	}
+
+	screen := newScreen(log)
+	defer screen.Flush()
+
+	done := make(chan struct{})
+	defer close(done)
+	go watchChild(filepath.Base(cmd.Args[0]), master, screen, log, stop, done)
+	go pty.StopOnCancel(ctx, cmd, done)
+
+	return cancelledErr(ctx, pty.Wait(cmd, master, screen))
+}
+
+// runThroughHelper is runLogged for a command the privileged helper runs as
+// root: its terminal output comes back over the helper's pipe, and
+// watchChild answers through it.
+func runThroughHelper(command string, args []string, log func(string)) error {
+	ctx, stop := context.WithCancelCause(runContext)
+	defer stop(nil)
+	screen := newScreen(log)
+	defer screen.Flush()
+
+	done := make(chan struct{})
+	defer close(done)
+	go watchChild(command, helperInput{helper}, screen, log, stop, done)
+
+	return cancelledErr(ctx, helper.Exec(ctx, command, args, auditCause, screen))
+}
+
+// helperInput types into the command the helper runs.
+type helperInput struct{ c *privileged.Client }
+
+func (h helperInput) Write(p []byte) (int, error) {
+	return len(p), h.c.Input(p)
+}
+
+// runOnTerminal is runLogged for commands that call sudo themselves (yay,
+// paru) and those run through the escalation backend (RunSudo, root hooks).
+// A pseudo-terminal would put them in a new session, where sudo's
+// credentials, cached per terminal session, don't apply and it would ask for
+// the password where nobody sees it. Piped, they stay on the controlling terminal.
+func runOnTerminal(cmd *exec.Cmd, log func(string)) error {
+	return runPiped(runContext, cmd, log)
+}
+
+// runPiped is the fallback without a terminal. Its output still goes through
+// a pty.Screen and watchChild answers its questions on a stdin pipe, as
+// runLogged does on the terminal. A background process the command leaves
+// behind keeps the output open, so reading stops pipeWaitDelay after the
+// command exited rather than at end of output.
+func runPiped(ctx context.Context, cmd *exec.Cmd, log func(string)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	screen := newScreen(log)
+	defer screen.Flush()
+	cmd.Stdout, cmd.Stderr = screen, screen
+	cmd.WaitDelay = pipeWaitDelay
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	// Its own process group, so StopOnCancel reaches what it starts too
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	// Nothing echoes the answers as a terminal would, so they'd run into the next line
+	answers := io.MultiWriter(screen, stdin)
+	go watchChild(filepath.Base(cmd.Args[0]), answers, screen, log, stop, done)
+	go pty.StopOnCancel(ctx, cmd, done)
+
+	err = cmd.Wait()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// It exited fine, only its leftovers held the output open
+		err = nil
+	}
+	return cancelledErr(ctx, err)
+}
+
+// cancelledErr reports a command stopped by ctx as why ctx was cancelled
+// rather than its exit status.
+func cancelledErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return err
+}
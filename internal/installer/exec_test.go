@@ -0,0 +1,50 @@
+// FILE: internal/installer/exec_test.go
+package installer
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeYay asks for a provider the way yay does and prints what it was told.
+const fakeYay = `#!/bin/sh
+echo ':: There are 2 providers available for java-runtime:'
+echo ':: Repository extra'
+echo '    1) jre-openjdk 2) jre17-openjdk'
+echo
+printf 'Enter a number (default=1): '
+read -r n
+echo "picked $n"
+`
+
+// yay and paru run piped (runOnTerminal), their questions still have to reach the user.
+func TestPipedCommandAnswersPrompts(t *testing.T) {
+	yay := filepath.Join(t.TempDir(), "yay")
+	if err := os.WriteFile(yay, []byte(fakeYay), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var asked []string
+	defer func(prev AskFunc) { UserPrompt = prev }(UserPrompt)
+	UserPrompt = func(question string, choices []string) (string, error) {
+		asked = append(asked, question)
+		if want := []string{"jre-openjdk", "jre17-openjdk"}; !reflect.DeepEqual(choices, want) {
+			t.Errorf("choices = %q, want %q", choices, want)
+		}
+		return "jre17-openjdk", nil
+	}
+
+	var out logLines
+	if err := runOnTerminal(exec.Command(yay), out.log); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"yay asks: Choose a provider for java-runtime"}; !reflect.DeepEqual(asked, want) {
+		t.Errorf("asked %q, want %q", asked, want)
+	}
+	if !out.contains("picked 2") {
+		t.Errorf("output %q, want the second provider picked", out.lines)
+	}
+}
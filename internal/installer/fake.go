@@ -0,0 +1,131 @@
+// FILE: internal/installer/fake.go
+package installer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FakePackageManager is an in-memory PackageManager so the engine can be
+// exercised without an Arch system. Install is all-or-nothing, like pacman.
+type FakePackageManager struct {
+	mu sync.Mutex
+
+	// Available is what the fake "repos" know about, keyed by name.
+	Available map[string]PackageInfo
+	// Installed is the fake local database.
+	Installed map[string]PackageInfo
+	// Fail makes installing a given package fail with that error.
+	Fail map[string]error
+	// Calls records every operation, e.g. "install a b" or "refresh".
+	Calls []string
+}
+
+// NewFakePackageManager returns a fake whose repos contain available.
+func NewFakePackageManager(available ...PackageInfo) *FakePackageManager {
+	f := &FakePackageManager{
+		Available: make(map[string]PackageInfo),
+		Installed: make(map[string]PackageInfo),
+		Fail:      make(map[string]error),
+	}
+	for _, pkg := range available {
+		f.Available[pkg.Name] = pkg
+	}
+	return f
+}
+
+func (f *FakePackageManager) Name() string { return "fake" }
+
+func (f *FakePackageManager) record(op string, pkgs []string) {
+	f.Calls = append(f.Calls, strings.TrimSpace(op+" "+strings.Join(pkgs, " ")))
+}
+
+func (f *FakePackageManager) Install(pkgs []string, log func(string)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("install", pkgs)
+
+	for _, name := range pkgs {
+		if _, ok := f.Available[name]; !ok {
+			return fmt.Errorf("target not found: %s", name)
+		}
+		if err := f.Fail[name]; err != nil {
+			return fmt.Errorf("failed to install %s: %w", name, err)
+		}
+	}
+	for _, name := range pkgs {
+		f.Installed[name] = f.Available[name]
+		log(fmt.Sprintf("installing %s...\n", name))
+	}
+	return nil
+}
+
+func (f *FakePackageManager) IsInstalled(pkg string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.Installed[pkg]
+	return ok, nil
+}
+
+func (f *FakePackageManager) Info(pkg string) (*PackageInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if info, ok := f.Available[pkg]; ok {
+		return &info, nil
+	}
+	if info, ok := f.Installed[pkg]; ok {
+		return &info, nil
+	}
+	return nil, fmt.Errorf("package %s not found", pkg)
+}
+
+func (f *FakePackageManager) Remove(pkgs []string, log func(string)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("remove", pkgs)
+
+	for _, name := range pkgs {
+		if _, ok := f.Installed[name]; !ok {
+			return fmt.Errorf("target not found: %s", name)
+		}
+	}
+	for _, name := range pkgs {
+		delete(f.Installed, name)
+		log(fmt.Sprintf("removing %s...\n", name))
+	}
+	return nil
+}
+
+func (f *FakePackageManager) Refresh(log func(string)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("refresh", nil)
+	return nil
+}
+
+// SyncPackages lets the fake stand in for pacman: everything not from the AUR is a repo package.
+func (f *FakePackageManager) SyncPackages() (map[string]bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make(map[string]bool)
+	for name, info := range f.Available {
+		if info.Repository != "aur" {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+// InstalledNames returns the installed package names, sorted.
+func (f *FakePackageManager) InstalledNames() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, 0, len(f.Installed))
+	for name := range f.Installed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
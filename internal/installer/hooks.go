@@ -0,0 +1,146 @@
+// FILE: internal/installer/hooks.go
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"guhwizard/internal/audit"
+	"guhwizard/internal/config"
+	"guhwizard/internal/escalate"
+	"guhwizard/internal/fs"
+	"guhwizard/internal/target"
+)
+
+// SelectionEnv exposes the final selections to hooks,
+// e.g. GUH_SELECTED_TERMINALS=kitty or GUH_SELECTED_BROWSERS=firefox,lynx.
+func SelectionEnv(cfg *config.Config) []string {
+	env := []string{"GUH_AUR_HELPER=" + cfg.Settings.AURHelper}
+
+	for _, step := range cfg.Steps {
+		var selected []string
+		for _, item := range step.Items {
+			if item.Selected {
+				selected = append(selected, item.Name)
+			}
+		}
+		env = append(env, fmt.Sprintf("GUH_SELECTED_%s=%s", envName(step.ID), strings.Join(selected, ",")))
+	}
+	return env
+}
+
+// envName turns a step id like "dev-tools" into "DEV_TOOLS".
+func envName(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+// RunHooks runs the hooks for one phase in order and stops at the first failure.
+// extraEnv is added on top of the selection variables (e.g. GUH_STEP for step hooks).
+func RunHooks(cfg *config.Config, phase string, hooks []config.Hook, extraEnv []string, log func(string)) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	env := append(SelectionEnv(cfg), "GUH_HOOK="+phase)
+	env = append(env, extraEnv...)
+
+	for i, hook := range hooks {
+		name := hook.Name
+		if name == "" {
+			name = fmt.Sprintf("%s #%d", phase, i+1)
+		}
+		log(fmt.Sprintf("Running hook: %s\n", name))
+
+		restore := withCause(fmt.Sprintf("hook %s: %s", phase, name))
+		err := runHook(hook, env, log)
+		restore()
+		if err != nil {
+			return fmt.Errorf("hook %s failed: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func runHook(hook config.Hook, env []string, log func(string)) error {
+	ctx := runContext
+	if hook.Timeout != "" {
+		timeout, err := time.ParseDuration(hook.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", hook.Timeout, err)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for k, v := range hook.Env {
+		env = append(env, k+"="+v)
+	}
+
+	var cmd *exec.Cmd
+	switch hook.RunAs {
+	case "", "user":
+		cmd = exec.Command("bash", "-c", hook.Command)
+		cmd.Env = append(os.Environ(), env...)
+		target.Adopt(cmd)
+	case "root":
+		if runningAsRoot() {
+			cmd = exec.Command("bash", "-c", hook.Command)
+			cmd.Env = append(os.Environ(), env...)
+			break
+		}
+		// Root hooks are arbitrary commands, so they can't go through the
+		// privileged helper's allow-list and use the escalation backend directly.
+		// It resets the environment, so ours is passed explicitly. Non-interactive,
+		// a password prompt would only hang until the timeout (see runOnTerminal)
+		backend := escalate.Current()
+		cmd = exec.Command(backend.Name, backend.Args(true, env, "bash", "-c", hook.Command)...)
+	default:
+		return fmt.Errorf("unknown run_as %q (expected user or root)", hook.RunAs)
+	}
+
+	if hook.Dir != "" {
+		dir, err := fs.ExpandHome(hook.Dir)
+		if err != nil {
+			return err
+		}
+		cmd.Dir = dir
+	}
+
+	var entry audit.Entry
+	if hook.RunAs == "root" {
+		entry = audit.Begin("hook", []string{"bash", "-c", hook.Command}, auditCause, currentUser(), privilegeVia())
+		if cmd.Dir != "" {
+			entry.Dir = cmd.Dir
+		}
+	}
+
+	// On timeout the hook's whole process group is stopped, not just bash.
+	// Through the backend it stays on our terminal, see runOnTerminal
+	var err error
+	if hook.RunAs == "root" && !runningAsRoot() {
+		err = runPiped(ctx, cmd, log)
+	} else {
+		err = runLoggedContext(ctx, cmd, log)
+	}
+	if hook.RunAs == "root" {
+		entry.Finish(err)
+		recordPrivileged(entry, log)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", hook.Timeout)
+	}
+	return err
+}
@@ -0,0 +1,203 @@
+// FILE: internal/installer/interactive.go
+package installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"guhwizard/internal/pty"
+)
+
+// promptSettle is how long a command must be quiet before its last line is
+// taken for a question: prompts don't end with a newline.
+const promptSettle = 300 * time.Millisecond
+
+// DefaultStallTimeout is used when the blueprint sets no stall_timeout.
+const DefaultStallTimeout = 10 * time.Minute
+
+// StallTimeout flags a command that printed nothing for that long, 0 turns it off.
+// The engine sets it from the blueprint for the duration of an install.
+var StallTimeout = DefaultStallTimeout
+
+// childPrompt is a question a command asks on its terminal, and what to type for each choice.
+type childPrompt struct {
+	question string
+	choices  []string
+	answers  []string
+	def      int
+}
+
+var (
+	// pacman/yay/paru provider selection, followed by "   1) jre-openjdk  2) jre17-openjdk"
+	numberPrompt  = regexp.MustCompile(`Enter a number \(default=(\d+)\):$`)
+	providersLine = regexp.MustCompile(`There are \d+ providers available for (.+):`)
+	providerEntry = regexp.MustCompile(`(\d+)\) (\S+)`)
+
+	// yay's menus for diffs, clean builds and edits, answered on the "==> " line
+	menuPrompt = regexp.MustCompile(`^==>$`)
+	menuLine   = regexp.MustCompile(`\[N\]one \[A\]ll \[Ab\]ort`)
+
+	// Key import and other confirmations: ":: Import? [Y/n]"
+	yesNoPrompt = regexp.MustCompile(`\[([Yy])/([Nn])\]:?$`)
+
+	// sudo, doas and polkit's text agent (run0, pkexec) asking for a password
+	passwordPrompt = regexp.MustCompile(`^\[sudo\] password for .*:$|^doas \(.*\) password:$|^Password:$`)
+)
+
+// errPasswordPrompt stops a command that asks for a password on its terminal:
+// nobody can type it there, the credentials have to be cached beforehand.
+var errPasswordPrompt = errors.New("asked for a password, which can't be entered during the install")
+
+// matchPrompt recognises a question in the last lines of a command's output.
+func matchPrompt(lines []string) *childPrompt {
+	if len(lines) == 0 {
+		return nil
+	}
+	last := strings.TrimSpace(lines[len(lines)-1])
+	before := lines[:len(lines)-1]
+
+	if m := numberPrompt.FindStringSubmatch(last); m != nil {
+		return providerPrompt(before, m[1])
+	}
+	if menuPrompt.MatchString(last) && len(before) >= 2 && menuLine.MatchString(before[len(before)-1]) {
+		return menuChoice(strings.TrimSpace(strings.TrimPrefix(before[len(before)-2], "==>")))
+	}
+	if m := yesNoPrompt.FindStringSubmatch(last); m != nil {
+		p := &childPrompt{
+			question: strings.Join(append(questionContext(before), strings.TrimSpace(strings.TrimPrefix(last, "::"))), "\n"),
+			choices:  []string{"Yes", "No"},
+			answers:  []string{"y", "n"},
+		}
+		if m[2] == "N" {
+			p.def = 1
+		}
+		return p
+	}
+	return nil
+}
+
+func providerPrompt(lines []string, def string) *childPrompt {
+	p := &childPrompt{question: "Choose a provider"}
+	for _, line := range lines {
+		if m := providersLine.FindStringSubmatch(line); m != nil {
+			p.question = fmt.Sprintf("Choose a provider for %s", m[1])
+			p.choices, p.answers = nil, nil
+			continue
+		}
+		for _, m := range providerEntry.FindAllStringSubmatch(line, -1) {
+			p.choices = append(p.choices, m[2])
+			p.answers = append(p.answers, m[1])
+		}
+	}
+	if len(p.choices) == 0 {
+		return nil
+	}
+	for i, answer := range p.answers {
+		if answer == def {
+			p.def = i
+		}
+	}
+	return p
+}
+
+// menuChoice answers yay's "[N]one [A]ll [Ab]ort" menus. Showing diffs or
+// editing PKGBUILDs would open a pager or editor no one can use, so those only offer None and Abort.
+func menuChoice(question string) *childPrompt {
+	p := &childPrompt{question: question, choices: []string{"None", "Abort"}, answers: []string{"N", "Ab"}}
+	lower := strings.ToLower(question)
+	if !strings.Contains(lower, "diff") && !strings.Contains(lower, "edit") {
+		p.choices = []string{"None", "All", "Abort"}
+		p.answers = []string{"N", "A", "Ab"}
+	}
+	return p
+}
+
+// questionContext is the paragraph a yes/no question closes, e.g. the keys
+// to import: back to its ":: " header, a blank line or at most 5 lines.
+func questionContext(lines []string) []string {
+	start := len(lines)
+	for start > 0 && len(lines)-start < 5 && strings.TrimSpace(lines[start-1]) != "" {
+		start--
+		if strings.HasPrefix(lines[start], "::") {
+			break
+		}
+	}
+	var out []string
+	for _, line := range lines[start:] {
+		out = append(out, strings.TrimSpace(strings.TrimPrefix(line, "::")))
+	}
+	return out
+}
+
+// answer asks the user, falling back to the command's default when no one can answer.
+func (p *childPrompt) answer(name string, log func(string)) string {
+	choice, err := ask(fmt.Sprintf("%s asks: %s", name, p.question), p.choices)
+	if err == nil {
+		for i, c := range p.choices {
+			if c == choice {
+				return p.answers[i]
+			}
+		}
+	}
+	log(fmt.Sprintf("No answer for %s's question, using its default (%s)\n", name, p.choices[p.def]))
+	return p.answers[p.def]
+}
+
+// matchPassword recognises an escalation backend's password prompt.
+func matchPassword(lines []string) bool {
+	return len(lines) > 0 && passwordPrompt.MatchString(strings.TrimSpace(lines[len(lines)-1]))
+}
+
+// watchChild answers the questions it recognises on a command's terminal by
+// typing into it (the ones the conflict plan decides without asking, see
+// plannedAnswer), and warns when the command goes quiet for StallTimeout.
+// A password prompt stops the command through stop with errPasswordPrompt.
+// It stops when done is closed.
+func watchChild(name string, term io.Writer, screen *pty.Screen, log func(string), stop context.CancelCauseFunc, done <-chan struct{}) {
+	ticker := time.NewTicker(promptSettle)
+	defer ticker.Stop()
+
+	var answered, stalled time.Time
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		last := screen.LastOutput()
+		quiet := time.Since(last)
+		if quiet >= promptSettle && !last.Equal(answered) {
+			if matchPassword(screen.Text()) {
+				stop(fmt.Errorf("%s %w", name, errPasswordPrompt))
+				return
+			}
+			if a, ok := plannedAnswer(screen.Text()); ok {
+				answered = last
+				term.Write([]byte(a + "\n"))
+				continue
+			}
+			if p := matchPrompt(screen.Text()); p != nil {
+				answered = last
+				term.Write([]byte(p.answer(name, log) + "\n"))
+				continue
+			}
+		}
+
+		if StallTimeout > 0 && quiet >= StallTimeout && !last.Equal(stalled) {
+			stalled = last
+			lines := screen.Text()
+			tail := ""
+			if len(lines) > 0 {
+				tail = ": " + strconv.Quote(lines[len(lines)-1])
+			}
+			log(fmt.Sprintf("Warning: %s has printed nothing for %s and may be waiting for input%s\n", name, StallTimeout, tail))
+		}
+	}
+}
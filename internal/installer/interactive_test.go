@@ -0,0 +1,130 @@
+// FILE: internal/installer/interactive_test.go
+package installer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchPrompt(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  *childPrompt
+	}{
+		{
+			name: "provider",
+			lines: []string{
+				":: There are 2 providers available for java-runtime:",
+				":: Repository extra",
+				"    1) jre-openjdk 2) jre17-openjdk",
+				"",
+				"Enter a number (default=2): ",
+			},
+			want: &childPrompt{
+				question: "Choose a provider for java-runtime",
+				choices:  []string{"jre-openjdk", "jre17-openjdk"},
+				answers:  []string{"1", "2"},
+				def:      1,
+			},
+		},
+		{
+			name:  "number without providers",
+			lines: []string{"Enter a number (default=1):"},
+		},
+		{
+			name: "yay diff menu",
+			lines: []string{
+				"==> Diffs to show?",
+				"==> [N]one [A]ll [Ab]ort [I]nstalled [No]tInstalled or (1 2 3, 1-3, ^4)",
+				"==> ",
+			},
+			want: &childPrompt{question: "Diffs to show?", choices: []string{"None", "Abort"}, answers: []string{"N", "Ab"}},
+		},
+		{
+			name: "yay clean build menu",
+			lines: []string{
+				"==> Packages to cleanBuild?",
+				"==> [N]one [A]ll [Ab]ort [I]nstalled [No]tInstalled or (1 2 3, 1-3, ^4)",
+				"==>",
+			},
+			want: &childPrompt{question: "Packages to cleanBuild?", choices: []string{"None", "All", "Abort"}, answers: []string{"N", "A", "Ab"}},
+		},
+		{
+			name: "key import",
+			lines: []string{
+				"==> Making package: foo 1.0-1",
+				"",
+				":: PGP keys need importing:",
+				" -> ABCD1234, required by: foo",
+				":: Import? [Y/n] ",
+			},
+			want: &childPrompt{
+				question: "PGP keys need importing:\n-> ABCD1234, required by: foo\nImport? [Y/n]",
+				choices:  []string{"Yes", "No"},
+				answers:  []string{"y", "n"},
+			},
+		},
+		{
+			name:  "default no",
+			lines: []string{":: Remove orphans? [y/N]:"},
+			want:  &childPrompt{question: "Remove orphans? [y/N]:", choices: []string{"Yes", "No"}, answers: []string{"y", "n"}, def: 1},
+		},
+		{
+			name:  "progress",
+			lines: []string{"(1/3) installing foo [#####-----]  50%"},
+		},
+		{name: "nothing"},
+	}
+	for _, tt := range tests {
+		if got := matchPrompt(tt.lines); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: matchPrompt = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQuestionContext(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{
+			name:  "back to the header",
+			lines: []string{"building foo", ":: Keys:", "  one", "  two"},
+			want:  []string{"Keys:", "one", "two"},
+		},
+		{
+			name:  "back to a blank line",
+			lines: []string{":: Header", "", "one", "two"},
+			want:  []string{"one", "two"},
+		},
+		{
+			name:  "at most 5 lines",
+			lines: []string{"1", "2", "3", "4", "5", "6", "7"},
+			want:  []string{"3", "4", "5", "6", "7"},
+		},
+		{
+			name:  "right after a blank line",
+			lines: []string{"output", ""},
+		},
+	}
+	for _, tt := range tests {
+		if got := questionContext(tt.lines); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: questionContext(%q) = %q, want %q", tt.name, tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPassword(t *testing.T) {
+	for line, want := range map[string]bool{
+		"[sudo] password for alice: ": true,
+		"doas (alice@host) password:": true,
+		"Password: ":                  true,
+		"password updated":            false,
+	} {
+		if got := matchPassword([]string{"output", line}); got != want {
+			t.Errorf("matchPassword(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
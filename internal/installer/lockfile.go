@@ -0,0 +1,307 @@
+// FILE: internal/installer/lockfile.go
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/fs"
+	"guhwizard/internal/target"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LockfileName is written next to the blueprint after a successful run.
+const LockfileName = "guhwizard.lock"
+
+// pacmanCacheDir is where pacman keeps downloaded packages, old versions included.
+const pacmanCacheDir = "/var/cache/pacman/pkg"
+
+// LockedPackage pins one installed package.
+type LockedPackage struct {
+	Name       string `yaml:"name"`
+	Version    string `yaml:"version"`
+	Repository string `yaml:"repository"`       // sync repo, or "aur"
+	Commit     string `yaml:"commit,omitempty"` // AUR git commit the package was built from
+}
+
+// Lockfile records what a run installed, so other machines can match it.
+type Lockfile struct {
+	Generated time.Time       `yaml:"generated"`
+	AURHelper string          `yaml:"aur_helper"`
+	Packages  []LockedPackage `yaml:"packages"`
+}
+
+// Find returns the entry for name, or nil.
+func (l *Lockfile) Find(name string) *LockedPackage {
+	for i := range l.Packages {
+		if l.Packages[i].Name == name {
+			return &l.Packages[i]
+		}
+	}
+	return nil
+}
+
+// commitSource is implemented by AUR backends that know which commit they built.
+type commitSource interface {
+	Commit(pkg string) string
+}
+
+// locked is set by `guhwizard install --locked`. InstallPackages and the
+// AUR builds pick it up.
+var locked *Lockfile
+
+// helperCommit is the commit InstallAURHelper built the helper from, if it built it.
+var helperCommit string
+
+// IsLocked reports whether installs follow a lockfile.
+func IsLocked() bool { return locked != nil }
+
+// ReadLockfile loads a lockfile written by WriteLockfile.
+func ReadLockfile(path string) (*Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lock Lockfile
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return &lock, nil
+}
+
+// UseLockfile makes the following installs stick to the versions in path.
+func UseLockfile(path string) error {
+	lock, err := ReadLockfile(path)
+	if err != nil {
+		return err
+	}
+	locked = lock
+	return nil
+}
+
+// writeUserFile writes a file guhwizard creates for the target user: as them
+// inside their home (fs.TargetWriteFile), otherwise as whoever runs guhwizard.
+func writeUserFile(path string, content []byte) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(abs, filepath.Clean(target.Current().Home)+"/") {
+		return fs.TargetWriteFile(abs, content, 0644)
+	}
+	return fs.AtomicWrite(abs, content, 0644)
+}
+
+// WriteLockfile records the installed version of every blueprint package the
+// run selected, plus the AUR helper. aur is asked for the commits it built from.
+func WriteLockfile(path string, cfg *config.Config, aur PackageManager, log func(string)) error {
+	local, err := LocalPackages()
+	if err != nil {
+		return fmt.Errorf("failed to read installed packages: %w", err)
+	}
+	sync, err := syncVersions()
+	if err != nil {
+		return fmt.Errorf("failed to read the sync databases: %w", err)
+	}
+
+	pkgs := SelectedPackages(cfg)
+	helper := cfg.Settings.AURHelper
+	if helper != "" && helper != NativeHelper {
+		pkgs = append(pkgs, helper)
+	}
+
+	lock := &Lockfile{Generated: time.Now().UTC().Truncate(time.Second), AURHelper: helper}
+	for _, pkg := range pkgs {
+		version, ok := local[pkg]
+		if !ok {
+			// Groups and provides don't show up under their own name, failed packages not at all
+			continue
+		}
+
+		entry := LockedPackage{Name: pkg, Version: version}
+		if s, ok := sync[pkg]; ok {
+			entry.Repository = s.repo
+		} else {
+			entry.Repository = "aur"
+			entry.Commit = lockedCommit(pkg, helper, aur)
+		}
+		lock.Packages = append(lock.Packages, entry)
+	}
+
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	header := "# Generated by guhwizard. Install these versions with 'guhwizard install --locked'.\n"
+	if err := writeUserFile(path, append([]byte(header), data...)); err != nil {
+		return err
+	}
+	log(fmt.Sprintf("Wrote %s (%d packages).\n", path, len(lock.Packages)))
+	return nil
+}
+
+// lockedCommit finds the commit an AUR package was built from, or "" if unknown.
+func lockedCommit(pkg, helper string, aur PackageManager) string {
+	if pkg == helper {
+		if helperCommit != "" {
+			return helperCommit
+		}
+		if locked != nil {
+			if prev := locked.Find(pkg); prev != nil {
+				return prev.Commit
+			}
+		}
+		return ""
+	}
+	if src, ok := aur.(commitSource); ok {
+		return src.Commit(pkg)
+	}
+	return ""
+}
+
+// applyLock installs the locked versions of pkgs that the repos no longer
+// carry from the package cache, and returns the packages left for a normal install.
+// Packages that already are at their locked version are dropped as well.
+func applyLock(pkgs []string, pacman *Pacman, log func(string)) []string {
+	local, err := LocalPackages()
+	if err != nil {
+		log(fmt.Sprintf("Warning: cannot read installed packages, ignoring the lockfile: %v\n", err))
+		return pkgs
+	}
+	sync, err := syncVersions()
+	if err != nil {
+		log(fmt.Sprintf("Warning: cannot read the sync databases, ignoring the lockfile: %v\n", err))
+		return pkgs
+	}
+
+	var rest, files, fromCache []string
+	for _, pkg := range pkgs {
+		entry := locked.Find(pkg)
+		if entry == nil {
+			log(fmt.Sprintf("Warning: %s is not in the lockfile, installing the current version.\n", pkg))
+			rest = append(rest, pkg)
+			continue
+		}
+		if local[pkg] == entry.Version {
+			// -S --needed would upgrade it if the repos moved on
+			continue
+		}
+		if entry.Repository == "aur" {
+			rest = append(rest, pkg)
+			continue
+		}
+
+		current, ok := sync[pkg]
+		if !ok || current.version == entry.Version {
+			rest = append(rest, pkg)
+			continue
+		}
+		if vercmp(current.version, entry.Version) > 0 {
+			log(fmt.Sprintf("Warning: %s is locked at %s, %s has the newer %s.\n", pkg, entry.Version, current.repo, current.version))
+		} else {
+			log(fmt.Sprintf("Warning: %s is locked at %s, %s has %s.\n", pkg, entry.Version, current.repo, current.version))
+		}
+
+		file := cachedPackage(pkg, entry.Version)
+		if file == "" {
+			log(fmt.Sprintf("%s %s is not in the package cache, installing %s instead.\n", pkg, entry.Version, current.version))
+			rest = append(rest, pkg)
+			continue
+		}
+		files = append(files, file)
+		fromCache = append(fromCache, pkg)
+	}
+
+	if len(files) > 0 {
+		log(fmt.Sprintf("Installing %d locked versions from the package cache...\n", len(files)))
+		if err := pacman.InstallFiles(files, log); err != nil {
+			log(fmt.Sprintf("Warning: installing from the cache failed, using the repo versions: %v\n", err))
+			rest = append(rest, fromCache...)
+		}
+	}
+	return rest
+}
+
+// lockedAURCommit returns the commit pkg is locked to, or "".
+func lockedAURCommit(pkg string) string {
+	if locked == nil {
+		return ""
+	}
+	if entry := locked.Find(pkg); entry != nil && entry.Repository == "aur" {
+		return entry.Commit
+	}
+	return ""
+}
+
+// cachedPackage looks for name at exactly version in the pacman cache.
+func cachedPackage(name, version string) string {
+	matches, _ := filepath.Glob(filepath.Join(pacmanCacheDir, fmt.Sprintf("%s-%s-*.pkg.tar.*", name, version)))
+	for _, path := range matches {
+		if p, ok := parsePackageFile(path); ok && p.Name == name && p.Version == version {
+			return path
+		}
+	}
+	return ""
+}
+
+// syncPackage is one `pacman -Sl` entry.
+type syncPackage struct {
+	repo    string
+	version string
+}
+
+// syncVersions lists the repo and version of every package in the sync databases.
+func syncVersions() (map[string]syncPackage, error) {
+	out, err := pacmanQuery("-Sl").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := make(map[string]syncPackage)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// "extra firefox 128.0-1 [installed]"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		if _, seen := pkgs[fields[1]]; !seen { // the first repo wins, as in pacman
+			pkgs[fields[1]] = syncPackage{repo: fields[0], version: fields[2]}
+		}
+	}
+	return pkgs, nil
+}
+
+// vercmp compares two package versions with pacman's vercmp (<0, 0, >0).
+func vercmp(a, b string) int {
+	out, err := exec.Command("vercmp", a, b).Output()
+	if err != nil {
+		return strings.Compare(a, b)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return strings.Compare(a, b)
+	}
+	return n
+}
+
+// headCommit returns the checked out commit of a git repo, or "".
+func headCommit(repoDir string) string {
+	// As the owner, git refuses repositories of other users
+	cmd := target.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
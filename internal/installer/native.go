@@ -0,0 +1,400 @@
+// FILE: internal/installer/native.go
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"guhwizard/internal/fs"
+	"guhwizard/internal/target"
+)
+
+// NativeHelper is the aur step choice that uses NativeAUR instead of yay or paru.
+const NativeHelper = "builtin"
+
+// NativeAUR builds AUR packages itself: clone, read .SRCINFO, resolve the
+// AUR-on-AUR dependency order, makepkg as the user, install with pacman -U.
+type NativeAUR struct {
+	// BaseURL is the AUR (https://aur.archlinux.org) or a local directory
+	// holding one git repo per package (<dir>/<pkgbase> or <dir>/<pkgbase>.git).
+	BaseURL string
+
+	pacman  *Pacman
+	commits map[string]string // pkgname -> commit it was built from
+}
+
+// NewNativeAUR returns the built-in builder. An empty baseURL means the official AUR.
+func NewNativeAUR(baseURL string) *NativeAUR {
+	if baseURL == "" {
+		baseURL = DefaultAURURL
+	}
+	return &NativeAUR{BaseURL: strings.TrimRight(baseURL, "/"), pacman: &Pacman{}, commits: make(map[string]string)}
+}
+
+func (n *NativeAUR) Name() string { return NativeHelper }
+
+func (n *NativeAUR) isLocal() bool {
+	return !strings.Contains(n.BaseURL, "://")
+}
+
+// aurBuild is one pkgbase to build, in dependency order.
+type aurBuild struct {
+	dir     string
+	info    *SrcInfo
+	targets []string // pkgnames of this base that were asked for (or needed)
+	asDeps  bool
+
+	files []BuiltPackage // filled in once built
+}
+
+func (n *NativeAUR) Install(pkgs []string, log func(string)) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+
+	buildDir, err := newBuildDir("aur")
+	if err != nil {
+		return fmt.Errorf("failed to create build directory: %w", err)
+	}
+	defer fs.TargetRemoveAll(buildDir)
+
+	_, err = n.build(pkgs, buildDir, true, log)
+	return err
+}
+
+// Build builds pkgs and copies the resulting package files into dest without
+// installing them. AUR dependencies still get installed, later builds need them.
+// It returns the runtime dependencies that are not part of the AUR builds,
+// whether or not they are installed here, so a bundle can ship them.
+func (n *NativeAUR) Build(pkgs []string, dest string, log func(string)) ([]string, error) {
+	buildDir, err := newBuildDir("aur")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create build directory: %w", err)
+	}
+	defer fs.TargetRemoveAll(buildDir)
+
+	built, err := n.build(pkgs, buildDir, false, log)
+	if err != nil {
+		return nil, err
+	}
+
+	fromAUR := make(map[string]bool)
+	for _, b := range built {
+		for _, name := range append(append([]string{}, b.info.Names...), b.info.Provides...) {
+			fromAUR[depName(name)] = true
+		}
+	}
+
+	var repoDeps []string
+	for _, b := range built {
+		for _, dep := range b.info.Depends {
+			if !fromAUR[depName(dep)] {
+				repoDeps = append(repoDeps, depName(dep))
+			}
+		}
+		for _, p := range b.files {
+			if err := fs.CopyFile(p.Path, filepath.Join(dest, filepath.Base(p.Path))); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return repoDeps, nil
+}
+
+// build resolves and builds pkgs in dependency order. Dependencies are always
+// installed; the requested packages only when installTargets is set.
+func (n *NativeAUR) build(pkgs []string, buildDir string, installTargets bool, log func(string)) ([]*aurBuild, error) {
+	plan, repoDeps, err := n.resolve(pkgs, buildDir, log)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(repoDeps) > 0 {
+		log(fmt.Sprintf("Installing %d repo dependencies...\n", len(repoDeps)))
+		if err := n.pacman.InstallAsDeps(repoDeps, log); err != nil {
+			return nil, fmt.Errorf("failed to install dependencies: %w", err)
+		}
+	}
+
+	// Each dependency is installed right away, later builds may need it
+	for _, b := range plan {
+		log(fmt.Sprintf("Building %s %s...\n", b.info.PkgBase, b.info.Version()))
+		pkgDir := filepath.Join(buildDir, "pkg", b.info.PkgBase)
+		if err := makepkg(b.dir, pkgDir, log); err != nil {
+			return nil, fmt.Errorf("build of %s failed: %w", b.info.PkgBase, err)
+		}
+
+		if b.files, err = builtPackages(pkgDir, b.targets); err != nil {
+			return nil, err
+		}
+		files := make([]string, len(b.files))
+		for i, p := range b.files {
+			files[i] = p.Path
+		}
+
+		switch {
+		case b.asDeps:
+			err = n.pacman.InstallFilesAsDeps(files, log)
+		case installTargets:
+			err = n.pacman.InstallFiles(files, log)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to install %s: %w", b.info.PkgBase, err)
+		}
+	}
+	return plan, nil
+}
+
+// resolve clones every needed AUR package and orders them so dependencies
+// are built first. Dependencies the repos can satisfy are returned separately.
+func (n *NativeAUR) resolve(pkgs []string, buildDir string, log func(string)) ([]*aurBuild, []string, error) {
+	byName := make(map[string]*aurBuild) // pkgname or provides -> build
+	var repoDeps []string
+	repoSeen := make(map[string]bool)
+
+	var order []*aurBuild
+	visiting := make(map[*aurBuild]bool)
+	done := make(map[*aurBuild]bool)
+
+	var visit func(name string, asDep bool) error
+	visit = func(name string, asDep bool) error {
+		b, ok := byName[name]
+		if !ok {
+			var err error
+			if b, err = n.fetch(name, buildDir, log); err != nil {
+				return err
+			}
+			b.asDeps = asDep
+			for _, p := range append(append([]string{}, b.info.Names...), b.info.Provides...) {
+				byName[depName(p)] = b
+			}
+		}
+		if !slices.Contains(b.targets, name) && slices.Contains(b.info.Names, name) {
+			b.targets = append(b.targets, name)
+		}
+		if !asDep {
+			b.asDeps = false
+		}
+
+		if done[b] {
+			return nil
+		}
+		if visiting[b] {
+			return fmt.Errorf("dependency cycle involving %s", b.info.PkgBase)
+		}
+		visiting[b] = true
+
+		deps := append(append(append([]string{}, b.info.Depends...), b.info.MakeDepends...), b.info.CheckDepends...)
+		unsatisfied, err := unsatisfiedDeps(deps)
+		if err != nil {
+			return err
+		}
+		for _, dep := range unsatisfied {
+			if _, ok := byName[depName(dep)]; !ok && repoSatisfies(dep) {
+				if !repoSeen[dep] {
+					repoSeen[dep] = true
+					repoDeps = append(repoDeps, dep)
+				}
+				continue
+			}
+			if err := visit(depName(dep), true); err != nil {
+				return fmt.Errorf("%s: %w", b.info.PkgBase, err)
+			}
+		}
+
+		visiting[b] = false
+		done[b] = true
+		order = append(order, b)
+		return nil
+	}
+
+	for _, pkg := range pkgs {
+		if err := visit(pkg, false); err != nil {
+			return nil, nil, err
+		}
+	}
+	return order, repoDeps, nil
+}
+
+// fetch clones one package and parses its .SRCINFO. With a lockfile the
+// clone is pinned to the locked commit, which needs the full history.
+func (n *NativeAUR) fetch(name, buildDir string, log func(string)) (*aurBuild, error) {
+	dir := filepath.Join(buildDir, "src", name)
+	log(fmt.Sprintf("Cloning %s...\n", name))
+
+	commit := lockedAURCommit(name)
+
+	// AUR repos are named after the pkgbase, which differs for split packages.
+	// It has to be looked up first: cloning a name that has no repo succeeds
+	// on aur.archlinux.org, with an empty repository.
+	base, err := n.pkgBase(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", name, err)
+	}
+	args := []string{"clone", "--depth=1", n.repoURL(base), dir}
+	if commit != "" {
+		args = []string{"clone", n.repoURL(base), dir}
+	}
+	if err := target.Command("git", args...).Run(); err != nil {
+		if base != name {
+			return nil, fmt.Errorf("failed to clone %s (%s): %v", name, base, err)
+		}
+		return nil, fmt.Errorf("failed to clone %s: %v", name, err)
+	}
+
+	if commit != "" {
+		log(fmt.Sprintf("Pinning %s to locked commit %s...\n", name, commit))
+		if err := checkoutCommit(dir, commit); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ".SRCINFO"))
+	if err != nil {
+		return nil, fmt.Errorf("%s has no .SRCINFO: %w", name, err)
+	}
+	info := ParseSrcInfo(data)
+	if head := headCommit(dir); head != "" {
+		for _, pkg := range info.Names {
+			n.commits[pkg] = head
+		}
+	}
+	return &aurBuild{dir: dir, info: info}, nil
+}
+
+// Commit returns the commit pkg was last built from by this builder.
+func (n *NativeAUR) Commit(pkg string) string {
+	return n.commits[pkg]
+}
+
+func (n *NativeAUR) repoURL(pkgbase string) string {
+	if n.isLocal() {
+		plain := filepath.Join(n.BaseURL, pkgbase)
+		if _, err := os.Stat(plain); err == nil {
+			return plain
+		}
+		return plain + ".git"
+	}
+	return fmt.Sprintf("%s/%s.git", n.BaseURL, pkgbase)
+}
+
+// rpcInfo is one result of the AUR RPC info endpoint.
+type rpcInfo struct {
+	Name        string   `json:"Name"`
+	PackageBase string   `json:"PackageBase"`
+	Version     string   `json:"Version"`
+	Depends     []string `json:"Depends"`
+	Provides    []string `json:"Provides"`
+	Conflicts   []string `json:"Conflicts"`
+	Replaces    []string `json:"Replaces"`
+}
+
+func (n *NativeAUR) rpcInfo(name string) (*rpcInfo, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(n.BaseURL + "/rpc/v5/info?" + url.Values{"arg[]": {name}}.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Error   string    `json:"error"`
+		Results []rpcInfo `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.Error != "" {
+		return nil, fmt.Errorf("%s", body.Error)
+	}
+	if len(body.Results) == 0 {
+		return nil, fmt.Errorf("package %s not found in the AUR", name)
+	}
+	return &body.Results[0], nil
+}
+
+func (n *NativeAUR) pkgBase(name string) (string, error) {
+	if n.isLocal() {
+		return name, nil
+	}
+	info, err := n.rpcInfo(name)
+	if err != nil {
+		return "", err
+	}
+	return info.PackageBase, nil
+}
+
+func (n *NativeAUR) IsInstalled(pkg string) (bool, error) {
+	return n.pacman.IsInstalled(pkg)
+}
+
+// Info reports AUR packages with Repository "aur", like yay and paru do.
+// A local directory is read directly: <dir>/<pkg>/.SRCINFO at HEAD.
+func (n *NativeAUR) Info(pkg string) (*PackageInfo, error) {
+	if n.isLocal() {
+		out, err := target.Command("git", "-C", n.repoURL(pkg), "show", "HEAD:.SRCINFO").Output()
+		if err != nil {
+			return nil, fmt.Errorf("package %s not found in %s", pkg, n.BaseURL)
+		}
+		src := ParseSrcInfo(out)
+		return &PackageInfo{
+			Name:       pkg,
+			Version:    src.Version(),
+			Repository: "aur",
+			Depends:    src.Depends,
+			Provides:   src.Provides,
+			Conflicts:  src.Conflicts,
+			Replaces:   src.Replaces,
+		}, nil
+	}
+
+	info, err := n.rpcInfo(pkg)
+	if err != nil {
+		return nil, err
+	}
+	return &PackageInfo{
+		Name:       info.Name,
+		Version:    info.Version,
+		Repository: "aur",
+		Depends:    info.Depends,
+		Provides:   info.Provides,
+		Conflicts:  info.Conflicts,
+		Replaces:   info.Replaces,
+	}, nil
+}
+
+func (n *NativeAUR) Remove(pkgs []string, log func(string)) error {
+	return n.pacman.Remove(pkgs, log)
+}
+
+func (n *NativeAUR) Refresh(log func(string)) error {
+	return n.pacman.Refresh(log)
+}
+
+// unsatisfiedDeps returns the deps not satisfied by installed packages (pacman -T).
+func unsatisfiedDeps(deps []string) ([]string, error) {
+	if len(deps) == 0 {
+		return nil, nil
+	}
+	out, err := pacmanQuery(append([]string{"-T"}, deps...)...).Output()
+	if err != nil {
+		// Exit status 127 just means some deps are missing, they are listed on stdout
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 127 {
+			return nil, fmt.Errorf("pacman -T failed: %w", err)
+		}
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// repoSatisfies reports whether a sync repo package provides dep.
+func repoSatisfies(dep string) bool {
+	return pacmanQuery("-Sp", "--print-format", "%n", dep).Run() == nil
+}
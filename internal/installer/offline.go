@@ -0,0 +1,130 @@
+// FILE: internal/installer/offline.go
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/target"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Layout of a bundle directory written by CreateBundle.
+const (
+	OfflineRepoName = "guhwizard-offline"
+	bundleManifest  = "bundle.yaml"
+	bundleRepoDir   = "repo"
+	bundleGitDir    = "git"
+	bundleScriptDir = "scripts"
+)
+
+// BundleManifest maps online sources to their bundled copies.
+type BundleManifest struct {
+	Git     map[string]string `yaml:"git"`     // clone URL -> git bundle (relative to the bundle dir)
+	Scripts map[string]string `yaml:"scripts"` // external script name -> script file
+}
+
+type offlineState struct {
+	dir        string
+	pacmanConf string
+	// rootConf is the privileged helper's copy of pacmanConf, written on first use
+	rootConf string
+	manifest BundleManifest
+}
+
+// offline is set while installing from a bundle. Every pacman call picks it up.
+var offline *offlineState
+
+// IsOffline reports whether installs come from a local bundle.
+func IsOffline() bool { return offline != nil }
+
+// EnableOffline points pacman, git clones and external scripts at the bundle in dir.
+// The returned func removes the temporary pacman.conf again.
+func EnableOffline(dir string) (func(), error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, bundleManifest))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a guhwizard bundle: %w", dir, err)
+	}
+	var manifest BundleManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", bundleManifest, err)
+	}
+
+	// The bundled repo is unsigned (repo-add without a key), so trust it explicitly.
+	// Nothing else is configured, pacman can't reach out to the network.
+	conf := fmt.Sprintf(`# Generated by guhwizard for an offline install
+[options]
+Architecture = auto
+SigLevel = Required DatabaseOptional
+LocalFileSigLevel = Optional
+
+[%s]
+SigLevel = Optional TrustAll
+Server = file://%s
+`, OfflineRepoName, filepath.Join(dir, bundleRepoDir))
+
+	f, err := os.CreateTemp("", "guhwizard-pacman-*.conf")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.WriteString(conf); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	f.Close()
+
+	offline = &offlineState{dir: dir, pacmanConf: f.Name(), manifest: manifest}
+	return func() {
+		os.Remove(f.Name())
+		offline = nil
+	}, nil
+}
+
+// helperConf returns the pacman.conf the privileged helper wrote for the
+// bundle, the only one it lets pacman run with.
+func (o *offlineState) helperConf() (string, error) {
+	if o.rootConf == "" {
+		conf, err := helper.OfflineConfig(OfflineRepoName, filepath.Join(o.dir, bundleRepoDir), auditCause)
+		if err != nil {
+			return "", fmt.Errorf("cannot set up the offline repository: %w", err)
+		}
+		o.rootConf = conf
+	}
+	return o.rootConf, nil
+}
+
+// gitClone clones url into dest, from its git bundle when offline.
+func gitClone(url, dest string) error {
+	if offline != nil {
+		bundle, ok := offline.manifest.Git[url]
+		if !ok {
+			return fmt.Errorf("%s is not in the offline bundle", url)
+		}
+		url = filepath.Join(offline.dir, bundle)
+	}
+
+	if out, err := target.Command("git", "clone", url, dest).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	return nil
+}
+
+// scriptCommand returns the command to run for an external script,
+// which is the bundled copy of its download when offline.
+func scriptCommand(script config.Script) string {
+	if offline != nil {
+		if file, ok := offline.manifest.Scripts[script.Name]; ok {
+			return fmt.Sprintf("bash %q", filepath.Join(offline.dir, file))
+		}
+	}
+	return script.Command
+}
@@ -2,118 +2,180 @@
 package installer
 
 import (
-	"bufio"
 	"fmt"
-	"guhwizard/internal/config"
 	"os"
 	"os/exec"
 	"path/filepath"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/fs"
+	"guhwizard/internal/target"
 )
 
-// InstallAURHelper installs the configured AUR helper (yay/paru).
+// InstallAURHelper bootstraps the configured AUR helper (yay, paru, or their -bin variants).
+// It is built in a private directory under the cache dir, optionally pinned to
+// a commit from the blueprint, and its PKGBUILD is shown for review first.
 func InstallAURHelper(cfg *config.Config, log func(string)) error {
 	helper := cfg.Settings.AURHelper
-	log(fmt.Sprintf("Checking for %s...", helper))
+	bin := HelperBinary(helper)
+
+	if helper == NativeHelper {
+		log("Using the built-in AUR builder, making sure git and base-devel are installed...\n")
+		return (&Pacman{}).Install([]string{"git", "base-devel"}, log)
+	}
+
+	log(fmt.Sprintf("Checking for %s...", bin))
 
-	if _, err := exec.LookPath(helper); err == nil {
+	if _, err := exec.LookPath(bin); err == nil {
 		log("Already installed.\n")
 		return nil
 	}
 
+	pacman := &Pacman{}
+
+	// Bundles ship the helper prebuilt in their repo
+	if IsOffline() {
+		log("Installing from the offline bundle...\n")
+		return pacman.Install([]string{helper}, log)
+	}
+
 	log("Installing git and base-devel...\n")
-	if err := RunSudo(log, "pacman", "-S", "--needed", "--noconfirm", "git", "base-devel"); err != nil {
+	if err := pacman.Install([]string{"git", "base-devel"}, log); err != nil {
 		return fmt.Errorf("failed to install base-devel: %v", err)
 	}
 
-	home, _ := os.UserHomeDir()
-	buildDir := filepath.Join(home, "Downloads", helper)
-	os.RemoveAll(buildDir)
+	buildDir, err := newBuildDir(helper)
+	if err != nil {
+		return fmt.Errorf("failed to create build directory: %w", err)
+	}
+	defer fs.TargetRemoveAll(buildDir)
+
+	srcDir := filepath.Join(buildDir, "src")
+	pkgDir := filepath.Join(buildDir, "pkg")
 
-	log(fmt.Sprintf("Cloning %s...", helper))
-	if err := exec.Command("git", "clone", fmt.Sprintf("https://aur.archlinux.org/%s.git", helper), buildDir).Run(); err != nil {
+	log(fmt.Sprintf("Cloning %s...\n", helper))
+	if err := target.Command("git", "clone", fmt.Sprintf("%s/%s.git", DefaultAURURL, helper), srcDir).Run(); err != nil {
 		return fmt.Errorf("failed to clone %s: %v", helper, err)
 	}
 
-	log("Building package...")
-	// makepkg -si without sudo prompt is tricky.
-	// We build with makepkg, then install with pacman -U using our RunSudo
-	buildCmd := exec.Command("makepkg", "-sfc", "--noconfirm")
-	buildCmd.Dir = buildDir
-	// makepkg might ask for sudo for deps if they aren't installed.
-	// The safest way is to ensure all deps are met or run makepkg such that it doesn't need root immediately?
-	// Actually, makepkg -si ASKS for sudo.
-	// We will trust the user to have base-devel installed (done above).
-	// The issue is if makepkg needs to install deps.
-	// We can try to rely on current session being cached, OR we accept that makepkg might fail if it needs root and can't get it.
-	// Enhanced approach: Use 'makepkg' (no install), then find the .pkg.tar.zst and install with RunSudo.
-
-	if out, err := buildCmd.CombinedOutput(); err != nil {
-		log(string(out))
+	commit := cfg.Settings.AURHelperCommits[helper]
+	if commit == "" {
+		commit = lockedAURCommit(helper)
+	}
+	if commit != "" {
+		log(fmt.Sprintf("Pinning %s to commit %s...\n", helper, commit))
+		if err := checkoutCommit(srcDir, commit); err != nil {
+			return err
+		}
+	}
+
+	if !cfg.Settings.SkipPKGBUILDReview {
+		if err := reviewPKGBUILD(srcDir, helper, log); err != nil {
+			return err
+		}
+	}
+
+	// The missing build deps are installed through RunSudo beforehand, makepkg
+	// -s would call sudo itself on its pseudo-terminal, where the cached
+	// credentials don't apply (and as root, as the target user, whose password
+	// we don't have). The package is then installed with pacman -U.
+	if err := installBuildDeps(srcDir, pacman, log); err != nil {
+		return err
+	}
+	log("Building package...\n")
+	if err := makepkg(srcDir, pkgDir, log, "-c"); err != nil {
 		return fmt.Errorf("build failed: %v", err)
 	}
 
-	matches, _ := filepath.Glob(filepath.Join(buildDir, "*.pkg.tar.zst"))
-	if len(matches) == 0 {
-		return fmt.Errorf("no package found in %s", buildDir)
+	helperCommit = headCommit(srcDir)
+
+	built, err := builtPackages(pkgDir, []string{helper})
+	if err != nil {
+		return err
 	}
 
-	log("Installing built package...\n")
-	return RunSudo(log, "pacman", "-U", "--noconfirm", matches[0])
+	log(fmt.Sprintf("Installing %s %s...\n", built[0].Name, built[0].Version))
+	return pacman.InstallFiles([]string{built[0].Path}, log)
 }
 
-// InstallPackages installs all selected packages from the config
-func InstallPackages(cfg *config.Config, log func(string)) error {
-	helper := cfg.Settings.AURHelper
-
-	// Collect all deps
-	deps := make([]string, 0)
-	deps = append(deps, cfg.Settings.BasePackages...)
-
-	// Add selected items from config
-	// Note: The UI Model should have populated a list of selected packages.
-	// However, the current architecture passed `cfg` around.
-	// The `UserConfig` struct in tasks.go was doing some transformations.
-	// We need to adhere to the config.Config structure.
-
-	// In the new architecture, we should iterate over Steps in Config, check .Selected on Items.
-	for _, step := range cfg.Steps {
-		for _, item := range step.Items {
-			if item.Selected {
-				deps = append(deps, item.Name)
-			}
-		}
+// installBuildDeps installs the missing dependencies the .SRCINFO in dir lists, as deps.
+func installBuildDeps(dir string, pacman *Pacman, log func(string)) error {
+	data, err := os.ReadFile(filepath.Join(dir, ".SRCINFO"))
+	if err != nil {
+		return fmt.Errorf("no .SRCINFO: %w", err)
+	}
+	info := ParseSrcInfo(data)
+	missing, err := unsatisfiedDeps(append(append(append([]string{}, info.Depends...), info.MakeDepends...), info.CheckDepends...))
+	if err != nil || len(missing) == 0 {
+		return err
 	}
+	log(fmt.Sprintf("Installing %d build dependencies...\n", len(missing)))
+	return pacman.InstallAsDeps(missing, log)
+}
 
+// InstallPackages installs the base packages and all selected items in two phases:
+// official repo packages first, in one pacman transaction, then AUR packages
+// one by one so a single broken build doesn't block the rest.
+func InstallPackages(cfg *config.Config, repo RepoManager, aur PackageManager, log func(string)) error {
+	deps := withoutKept(SelectedPackages(cfg), log)
 	if len(deps) == 0 {
 		log("No packages to install.\n")
 		return nil
 	}
 
-	log(fmt.Sprintf("Installing %d packages using %s...\n", len(deps), helper))
+	if locked != nil {
+		deps = applyLock(deps, &Pacman{}, log)
+	}
 
-	// Batch install
-	args := append([]string{"-S", "--noconfirm", "--needed"}, deps...)
+	log(fmt.Sprintf("Resolving %d packages...\n", len(deps)))
+	classes, err := ClassifyPackages(deps, repo, aur)
+	if err != nil {
+		return err
+	}
+	log(fmt.Sprintf("%d from repos, %d from the AUR, %d unknown.\n", len(classes.Repo), len(classes.AUR), len(classes.Unknown)))
 
-	// Aur helpers usually don't need sudo for the fetch/build part, but need it for install.
-	// yay/paru handle sudo internally.
-	// Since we don't have a PTY to pass to yay, we rely on the sudo cache we refreshed?
-	// NO. yay/paru will prompt if they need absolute root and can't find it.
-	// BUT we have a problem: RunSudo runs `sudo cmd`. We cannot run `sudo yay`.
-	// We should treat yay as a user command that MIGHT ask for sudo.
-	// Since we are running `sudo -v` in the background (KeepAlive), sudo should remain cached.
+	failed := make(map[string]error)
 
-	cmd := exec.Command(helper, args...)
-	pipe, _ := cmd.StdoutPipe()
-	cmd.Stderr = cmd.Stdout
+	// 1. Official repos, one transaction (bisected if it fails)
+	if len(classes.Repo) > 0 {
+		log(fmt.Sprintf("Installing %d repo packages with %s...\n", len(classes.Repo), repo.Name()))
+		restore := withCause(packageCause(cfg, classes.Repo))
+		bisectInstall(repo, classes.Repo, log, failed)
+		restore()
+	}
 
-	if err := cmd.Start(); err != nil {
-		return err
+	for _, pkg := range classes.Unknown {
+		log(fmt.Sprintf("Warning: %s was not found in the repos or the AUR.\n", pkg))
+		failed[pkg] = fmt.Errorf("not found in the repos or the AUR")
+	}
+
+	if locked != nil && len(classes.AUR) > 0 {
+		if _, native := aur.(*NativeAUR); !native {
+			log(fmt.Sprintf("Warning: %s builds the current AUR versions, only the built-in builder can use locked commits.\n", aur.Name()))
+		}
+	}
+
+	// 2. AUR, one build at a time, as the user. The built-in builder installs
+	// what it built through RunSudo, yay and paru call sudo themselves on the
+	// credentials cached for this terminal (see escalationFlags).
+	for i, pkg := range classes.AUR {
+		log(fmt.Sprintf("[%d/%d] Building %s with %s...\n", i+1, len(classes.AUR), pkg, aur.Name()))
+		restore := withCause(packageCause(cfg, []string{pkg}))
+		ok := installOne(aur, pkg, log, failed)
+		restore()
+		if ok {
+			log(fmt.Sprintf("Installed %s.\n", pkg))
+		}
 	}
 
-	scanner := bufio.NewScanner(pipe)
-	for scanner.Scan() {
-		log(scanner.Text() + "\n")
+	if len(failed) == 0 {
+		return nil
 	}
 
-	return cmd.Wait()
+	reportFailures(failed, deps, log)
+	if cfg.Settings.ErrorPolicy == "continue" {
+		log("Continuing anyway (error_policy: continue).\n")
+		return nil
+	}
+	return fmt.Errorf("%d packages failed: %s", len(failed), formatFailures(failed, deps))
 }
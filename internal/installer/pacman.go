@@ -0,0 +1,198 @@
+// FILE: internal/installer/pacman.go
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"guhwizard/internal/target"
+)
+
+// Pacman talks to pacman directly. Everything that changes the system goes through RunSudo.
+type Pacman struct{}
+
+func (p *Pacman) Name() string { return "pacman" }
+
+func (p *Pacman) Install(pkgs []string, log func(string)) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	args := append([]string{"-S", "--needed", "--noconfirm"}, pkgs...)
+	return runPacman(log, args...)
+}
+
+// InstallAsDeps installs packages marked as dependencies, so -Rns can clean them up later.
+func (p *Pacman) InstallAsDeps(pkgs []string, log func(string)) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	args := append([]string{"-S", "--needed", "--noconfirm", "--asdeps"}, pkgs...)
+	return runPacman(log, args...)
+}
+
+// InstallFiles installs locally built package files (pacman -U).
+func (p *Pacman) InstallFiles(files []string, log func(string)) error {
+	if len(files) == 0 {
+		return nil
+	}
+	args := append([]string{"-U", "--noconfirm"}, files...)
+	return runPacman(log, args...)
+}
+
+// InstallFilesAsDeps is InstallFiles for packages only pulled in as dependencies.
+func (p *Pacman) InstallFilesAsDeps(files []string, log func(string)) error {
+	if len(files) == 0 {
+		return nil
+	}
+	args := append([]string{"-U", "--noconfirm", "--asdeps"}, files...)
+	return runPacman(log, args...)
+}
+
+func (p *Pacman) IsInstalled(pkg string) (bool, error) {
+	return queryInstalled("pacman", pkg)
+}
+
+func (p *Pacman) Info(pkg string) (*PackageInfo, error) {
+	return queryInfo("pacman", pkg)
+}
+
+func (p *Pacman) Remove(pkgs []string, log func(string)) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	args := append([]string{"-Rns", "--noconfirm"}, pkgs...)
+	return runPacman(log, args...)
+}
+
+func (p *Pacman) Refresh(log func(string)) error {
+	return runPacman(log, "-Sy", "--noconfirm")
+}
+
+// SyncPackages lists every package in the sync databases (pacman -Slq) plus group names.
+func (p *Pacman) SyncPackages() (map[string]bool, error) {
+	out, err := pacmanQuery("-Slq").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		names[strings.TrimSpace(scanner.Text())] = true
+	}
+
+	// Groups are valid -S targets too. `pacman -Sg` prints the group name first on each line.
+	if out, err := pacmanQuery("-Sg").Output(); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+				names[fields[0]] = true
+			}
+		}
+	}
+	return names, nil
+}
+
+// LocalPackages returns every installed package with its version (pacman -Q).
+func LocalPackages() (map[string]string, error) {
+	out, err := pacmanQuery("-Q").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 {
+			versions[fields[0]] = fields[1]
+		}
+	}
+	return versions, nil
+}
+
+// runPacman runs a pacman transaction as root. Through the privileged helper
+// the offline configuration is the helper's own copy and -U installs package
+// files it staged, it takes neither from the user. An install replacing
+// installed packages answers pacman's questions, see confirmReplacements.
+func runPacman(log func(string), args ...string) error {
+	args = confirmReplacements(args)
+	if helper == nil {
+		return RunSudo(log, "pacman", pacmanArgs(args...)...)
+	}
+
+	args = append([]string{}, args...)
+	if slices.Contains(args, "-U") {
+		for i, arg := range args {
+			if strings.HasPrefix(arg, "-") || strings.Contains(arg, "://") {
+				continue
+			}
+			staged, err := helper.Stage(arg, auditCause)
+			if err != nil {
+				return fmt.Errorf("cannot stage %s: %w", filepath.Base(arg), err)
+			}
+			args[i] = staged
+		}
+	}
+	if offline != nil {
+		conf, err := offline.helperConf()
+		if err != nil {
+			return err
+		}
+		args = append([]string{"--config", conf}, args...)
+	}
+	return RunSudo(log, "pacman", args...)
+}
+
+// pacmanArgs points pacman at the offline configuration when one is active.
+func pacmanArgs(args ...string) []string {
+	if offline != nil {
+		return append([]string{"--config", offline.pacmanConf}, args...)
+	}
+	return args
+}
+
+// pacmanQuery builds a read-only pacman command (no root needed).
+func pacmanQuery(args ...string) *exec.Cmd {
+	return exec.Command("pacman", pacmanArgs(args...)...)
+}
+
+// toolQuery is pacmanQuery for pacman, and a command as the target user for
+// the AUR helpers (they refuse to run as root).
+func toolQuery(bin string, args ...string) *exec.Cmd {
+	if bin == "pacman" {
+		return pacmanQuery(args...)
+	}
+	return target.Command(bin, args...)
+}
+
+// queryInstalled runs `<bin> -Q pkg`. Exit status 1 just means "not installed".
+func queryInstalled(bin, pkg string) (bool, error) {
+	err := toolQuery(bin, "-Q", pkg).Run()
+	if err == nil {
+		return true, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return false, err
+}
+
+// queryInfo asks the sync databases first and falls back to the local one.
+func queryInfo(bin, pkg string) (*PackageInfo, error) {
+	out, err := toolQuery(bin, "-Si", pkg).Output()
+	if err != nil {
+		out, err = toolQuery(bin, "-Qi", pkg).Output()
+		if err != nil {
+			return nil, fmt.Errorf("package %s not found", pkg)
+		}
+	}
+	return parseInfo(out)
+}
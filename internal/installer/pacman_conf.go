@@ -0,0 +1,141 @@
+// FILE: internal/installer/pacman_conf.go
+package installer
+
+import (
+	"fmt"
+	"os"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/pacmanconf"
+)
+
+const (
+	PacmanConfPath = pacmanconf.Path
+	// PacmanConfBackup holds the pacman.conf from before guhwizard first touched it
+	PacmanConfBackup = pacmanconf.BackupPath
+
+	// PacmanStepID is the system step whose items tune pacman.conf
+	PacmanStepID = "pacman"
+
+	defaultParallelDownloads = 5
+)
+
+// pacmanConfEdits are the changes the selections make to pacman.conf.
+func pacmanConfEdits(cfg *config.Config) (*pacmanconf.Edits, error) {
+	edits := &pacmanconf.Edits{}
+	for _, step := range cfg.Steps {
+		if step.ID != PacmanStepID {
+			continue
+		}
+		for _, item := range step.Items {
+			if !item.Selected {
+				continue
+			}
+			switch item.Name {
+			case "multilib":
+				edits.EnableRepos = append(edits.EnableRepos, "multilib")
+			case "parallel-downloads":
+				edits.ParallelDownloads = cfg.Settings.PacmanConf.ParallelDownloads
+				if edits.ParallelDownloads <= 0 {
+					edits.ParallelDownloads = defaultParallelDownloads
+				}
+			case "color":
+				edits.Flags = append(edits.Flags, "Color")
+			case "ilovecandy":
+				edits.Flags = append(edits.Flags, "ILoveCandy")
+			default:
+				return nil, fmt.Errorf("unknown pacman.conf option %q", item.Name)
+			}
+		}
+	}
+
+	edits.Repos = append(edits.Repos, cfg.Settings.PacmanConf.Repos...)
+	// Offline installs never reach third-party mirrors
+	if !IsOffline() {
+		for _, repo := range cfg.Settings.Repositories {
+			edits.Repos = append(edits.Repos, repoSection(repo))
+		}
+	}
+	return edits, nil
+}
+
+// PlanPacmanConf returns pacman.conf as it is now and as the selections would leave it.
+func PlanPacmanConf(cfg *config.Config) (before, after string, err error) {
+	_, before, after, err = planPacmanConf(cfg)
+	return before, after, err
+}
+
+func planPacmanConf(cfg *config.Config) (edits *pacmanconf.Edits, before, after string, err error) {
+	edits, err = pacmanConfEdits(cfg)
+	if err != nil {
+		return nil, "", "", err
+	}
+	data, err := os.ReadFile(PacmanConfPath)
+	if err != nil {
+		return nil, "", "", err
+	}
+	f := pacmanconf.Parse(string(data))
+	if err := edits.Apply(f); err != nil {
+		return nil, "", "", err
+	}
+	return edits, string(data), f.String(), nil
+}
+
+// PacmanConfDiff is the change ApplyPacmanConf would make, for the confirmation screen.
+func PacmanConfDiff(cfg *config.Config) (string, error) {
+	before, after, err := PlanPacmanConf(cfg)
+	if err != nil {
+		return "", err
+	}
+	return pacmanconf.Diff(before, after), nil
+}
+
+// ApplyPacmanConf makes the planned edits. The original is kept as
+// PacmanConfBackup (only the first time) so RevertPacmanConf can restore it.
+// The privileged helper edits the file itself, see privileged.Client.EditPacmanConf.
+func ApplyPacmanConf(cfg *config.Config, log func(string)) (bool, error) {
+	edits, before, after, err := planPacmanConf(cfg)
+	if err != nil {
+		return false, fmt.Errorf("failed to plan pacman.conf changes: %w", err)
+	}
+	if before == after {
+		log("pacman.conf is already up to date.\n")
+		return false, nil
+	}
+
+	log(pacmanconf.Diff(before, after))
+
+	if helper != nil {
+		if err := helper.EditPacmanConf(edits, auditCause); err != nil {
+			return false, fmt.Errorf("failed to edit pacman.conf: %w", err)
+		}
+		return true, nil
+	}
+
+	log(fmt.Sprintf("Backing up %s to %s...\n", PacmanConfPath, PacmanConfBackup))
+	if err := RunSudo(log, "cp", "-n", "-a", PacmanConfPath, PacmanConfBackup); err != nil {
+		return false, fmt.Errorf("failed to back up pacman.conf: %w", err)
+	}
+
+	if err := WriteRootFile(PacmanConfPath, []byte(after), 0644, log); err != nil {
+		return false, fmt.Errorf("failed to write pacman.conf: %w", err)
+	}
+	return true, nil
+}
+
+// RevertPacmanConf restores the pacman.conf guhwizard backed up.
+func RevertPacmanConf(log func(string)) error {
+	data, err := os.ReadFile(PacmanConfBackup)
+	if err != nil {
+		return fmt.Errorf("no backup to restore: %w", err)
+	}
+
+	log(fmt.Sprintf("Restoring %s from %s...\n", PacmanConfPath, PacmanConfBackup))
+	if helper != nil {
+		return helper.RestorePacmanConf(auditCause)
+	}
+	if err := WriteRootFile(PacmanConfPath, data, 0644, log); err != nil {
+		return err
+	}
+	return RunSudo(log, "rm", "-f", PacmanConfBackup)
+}
@@ -0,0 +1,138 @@
+// FILE: internal/installer/pkgmanager.go
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/escalate"
+	"guhwizard/internal/target"
+)
+
+// PackageInfo is the subset of `-Si`/`-Qi` output the installer cares about.
+type PackageInfo struct {
+	Name       string
+	Version    string
+	Repository string // "core", "extra", "aur"... Empty for packages only known locally.
+	Depends    []string
+	Provides   []string
+	Conflicts  []string
+	Replaces   []string
+}
+
+// PackageManager is the one place that knows how to drive pacman or an AUR helper.
+// Each backend handles its own flags and output parsing.
+type PackageManager interface {
+	Name() string
+	Install(pkgs []string, log func(string)) error
+	IsInstalled(pkg string) (bool, error)
+	Info(pkg string) (*PackageInfo, error)
+	Remove(pkgs []string, log func(string)) error
+	Refresh(log func(string)) error
+}
+
+// NewPackageManager returns the backend for the configured helper name.
+func NewPackageManager(name string) (PackageManager, error) {
+	switch HelperBinary(name) {
+	case "pacman":
+		return &Pacman{}, nil
+	case "yay":
+		return NewYay(), nil
+	case "paru":
+		return NewParu(), nil
+	}
+	return nil, fmt.Errorf("unsupported package manager %q", name)
+}
+
+// NewAURBackend returns the backend used for AUR packages: the configured helper,
+// or the built-in builder when the aur step chose NativeHelper or the helper
+// can't be used (AURHelperOverride).
+func NewAURBackend(cfg *config.Config) (PackageManager, error) {
+	if cfg.Settings.AURHelper == NativeHelper || AURHelperOverride(cfg) != "" {
+		return NewNativeAUR(cfg.Settings.AURURL), nil
+	}
+	return NewPackageManager(cfg.Settings.AURHelper)
+}
+
+// AURHelperOverride explains why the built-in builder replaces the configured
+// AUR helper, empty when it doesn't. As root yay and paru would run as the
+// target user and ask for their password through sudo to install what they
+// built. doas and run0 keep no credentials they could reuse (see
+// escalate.Backend.NoPersistence), so they would ask for every package.
+func AURHelperOverride(cfg *config.Config) string {
+	if cfg.Settings.AURHelper == NativeHelper {
+		return ""
+	}
+	if runningAsRoot() {
+		return fmt.Sprintf("guhwizard runs as root, where %s would need %s's password: AUR packages are built with the built-in builder instead",
+			cfg.Settings.AURHelper, target.Current().Name)
+	}
+	if backend := escalate.Current(); backend.NoPersistence != "" {
+		return fmt.Sprintf("%s would ask for the password for every install through %s: AUR packages are built with the built-in builder instead",
+			cfg.Settings.AURHelper, backend.Name)
+	}
+	return ""
+}
+
+// parseInfo reads pacman-style "Key : Value" blocks, as printed by -Si/-Qi
+// (and by yay/paru, which reuse the format). Only the first block is used.
+func parseInfo(out []byte) (*PackageInfo, error) {
+	info := &PackageInfo{}
+	var lastKey string
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if info.Name != "" {
+				break // End of the first package block
+			}
+			continue
+		}
+
+		key, value := lastKey, line
+		// Continuation lines of a long list are indented and have no "Key :"
+		if !strings.HasPrefix(line, " ") {
+			k, v, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			key, value = strings.TrimSpace(k), v
+		}
+		lastKey = key
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Name":
+			info.Name = value
+		case "Version":
+			info.Version = value
+		case "Repository":
+			info.Repository = value
+		case "Depends On":
+			info.Depends = append(info.Depends, splitList(value)...)
+		case "Provides":
+			info.Provides = append(info.Provides, splitList(value)...)
+		case "Conflicts With":
+			info.Conflicts = append(info.Conflicts, splitList(value)...)
+		case "Replaces":
+			info.Replaces = append(info.Replaces, splitList(value)...)
+		}
+	}
+
+	if info.Name == "" {
+		return nil, fmt.Errorf("no package information in output")
+	}
+	return info, nil
+}
+
+// splitList splits a whitespace separated field, treating "None" as empty.
+func splitList(value string) []string {
+	if value == "None" {
+		return nil
+	}
+	return strings.Fields(value)
+}
@@ -0,0 +1,20 @@
+// FILE: internal/installer/prompt.go
+package installer
+
+import "errors"
+
+// AskFunc asks the user a question and blocks until it is answered.
+type AskFunc func(question string, choices []string) (string, error)
+
+// UserPrompt is how installer steps reach the user (TUI dialog or control socket).
+// The engine points it at Runner.Ask for the duration of an install.
+var UserPrompt AskFunc
+
+var errNoPrompter = errors.New("no one is available to answer prompts")
+
+func ask(question string, choices []string) (string, error) {
+	if UserPrompt == nil {
+		return "", errNoPrompter
+	}
+	return UserPrompt(question, choices)
+}
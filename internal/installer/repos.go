@@ -0,0 +1,53 @@
+// FILE: internal/installer/repos.go
+package installer
+
+import (
+	"fmt"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/pacmanconf"
+)
+
+const defaultKeyserver = "hkps://keyserver.ubuntu.com"
+
+// PrepareRepositories imports and locally signs the keys of every third-party
+// repo and installs their keyring/mirrorlist packages. The repo sections are
+// added to pacman.conf afterwards by ApplyPacmanConf, once their Include files exist.
+func PrepareRepositories(cfg *config.Config, log func(string)) error {
+	pacman := &Pacman{}
+
+	for _, repo := range cfg.Settings.Repositories {
+		log(fmt.Sprintf("Setting up repository [%s]...\n", repo.Name))
+
+		keyserver := repo.Keyserver
+		if keyserver == "" {
+			keyserver = defaultKeyserver
+		}
+		for _, key := range repo.KeyIDs {
+			log(fmt.Sprintf("Importing key %s...\n", key))
+			if err := RunSudo(log, "pacman-key", "--recv-keys", key, "--keyserver", keyserver); err != nil {
+				return fmt.Errorf("failed to import key %s for [%s]: %w", key, repo.Name, err)
+			}
+			if err := RunSudo(log, "pacman-key", "--lsign-key", key); err != nil {
+				return fmt.Errorf("failed to sign key %s for [%s]: %w", key, repo.Name, err)
+			}
+		}
+
+		if len(repo.Packages) > 0 {
+			if err := pacman.InstallFiles(repo.Packages, log); err != nil {
+				return fmt.Errorf("failed to install keyring/mirrorlist for [%s]: %w", repo.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// repoSection turns a blueprint repository into its pacman.conf section.
+func repoSection(repo config.ThirdPartyRepo) pacmanconf.Repo {
+	return pacmanconf.Repo{
+		Name:     repo.Name,
+		SigLevel: repo.SigLevel,
+		Servers:  repo.Servers,
+		Include:  repo.Mirrorlist,
+	}
+}
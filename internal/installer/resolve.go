@@ -0,0 +1,278 @@
+// FILE: internal/installer/resolve.go
+package installer
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"guhwizard/internal/config"
+)
+
+// DefaultAURURL is where AUR packages are looked up and cloned from.
+const DefaultAURURL = "https://aur.archlinux.org"
+
+// aurBatchSize keeps RPC query strings well under URL length limits.
+const aurBatchSize = 100
+
+// PackageIndex answers whether package names exist, without needing an AUR helper.
+// It is built either from the live system (pacman + AUR RPC) or from a snapshot directory.
+type PackageIndex struct {
+	Repo map[string]bool
+	// AUR returns which of names exist in the AUR.
+	AUR func(names []string) (map[string]bool, error)
+}
+
+// OnlineIndex uses the local sync databases and the AUR RPC interface.
+func OnlineIndex() (*PackageIndex, error) {
+	repo, err := (&Pacman{}).SyncPackages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync databases: %w", err)
+	}
+	return &PackageIndex{Repo: repo, AUR: queryAURRPC}, nil
+}
+
+// SnapshotIndex reads a local snapshot for offline checks. dir holds pacman
+// sync databases (*.db, gzip or plain tar) and optionally the AUR name list,
+// either as downloaded from aur.archlinux.org/packages.gz or as plain packages.txt.
+func SnapshotIndex(dir string) (*PackageIndex, error) {
+	dbs, _ := filepath.Glob(filepath.Join(dir, "*.db"))
+	if len(dbs) == 0 {
+		return nil, fmt.Errorf("no *.db sync databases in %s", dir)
+	}
+
+	repo := make(map[string]bool)
+	for _, db := range dbs {
+		if err := readSyncDB(db, repo); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", db, err)
+		}
+	}
+
+	aur := make(map[string]bool)
+	for _, name := range []string{"packages.gz", "packages.txt"} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := readNameList(path, aur); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+
+	return &PackageIndex{
+		Repo: repo,
+		AUR: func(names []string) (map[string]bool, error) {
+			found := make(map[string]bool)
+			for _, n := range names {
+				if aur[n] {
+					found[n] = true
+				}
+			}
+			return found, nil
+		},
+	}, nil
+}
+
+// Unresolved returns the names found neither in the repos nor in the AUR, in input order.
+func (idx *PackageIndex) Unresolved(pkgs []string) ([]string, error) {
+	var notRepo []string
+	for _, p := range pkgs {
+		if !idx.Repo[p] {
+			notRepo = append(notRepo, p)
+		}
+	}
+	if len(notRepo) == 0 {
+		return nil, nil
+	}
+
+	inAUR, err := idx.AUR(notRepo)
+	if err != nil {
+		return nil, fmt.Errorf("AUR lookup failed: %w", err)
+	}
+
+	var missing []string
+	for _, p := range notRepo {
+		if !inAUR[p] {
+			missing = append(missing, p)
+		}
+	}
+	return missing, nil
+}
+
+// BlueprintPackages lists every package the blueprint could install,
+// whether or not it is currently selected.
+func BlueprintPackages(cfg *config.Config) []string {
+	seen := make(map[string]bool)
+	var pkgs []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			pkgs = append(pkgs, name)
+		}
+	}
+
+	for _, name := range cfg.Settings.BasePackages {
+		add(name)
+	}
+	for _, step := range cfg.Steps {
+		for _, item := range step.Items {
+			if isPackageItem(step, item) {
+				add(item.Name)
+			}
+		}
+	}
+	return pkgs
+}
+
+func queryAURRPC(names []string) (map[string]bool, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	found := make(map[string]bool)
+
+	for start := 0; start < len(names); start += aurBatchSize {
+		end := min(start+aurBatchSize, len(names))
+
+		q := url.Values{}
+		for _, n := range names[start:end] {
+			q.Add("arg[]", n)
+		}
+
+		resp, err := client.Get(DefaultAURURL + "/rpc/v5/info?" + q.Encode())
+		if err != nil {
+			return nil, err
+		}
+
+		var body struct {
+			Error   string `json:"error"`
+			Results []struct {
+				Name string `json:"Name"`
+			} `json:"results"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if body.Error != "" {
+			return nil, fmt.Errorf("%s", body.Error)
+		}
+
+		for _, r := range body.Results {
+			found[r.Name] = true
+		}
+	}
+	return found, nil
+}
+
+// readSyncDB collects what -S accepts from a pacman sync database: the
+// package names, their groups and what they provide, like OnlineIndex.
+// Each package is a "<name>-<ver>-<rel>/desc" entry with a %NAME% field.
+func readSyncDB(path string, names map[string]bool) error {
+	r, closeFn, err := openMaybeGzip(path)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if filepath.Base(hdr.Name) != "desc" {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if name := descField(data, "%NAME%"); name != "" {
+			names[name] = true
+		}
+		for _, group := range descList(data, "%GROUPS%") {
+			names[group] = true
+		}
+		for _, provide := range descList(data, "%PROVIDES%") {
+			names[depName(provide)] = true
+		}
+	}
+}
+
+// descField returns the first line following a %FIELD% header in a desc file.
+func descField(desc []byte, field string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(desc))
+	for scanner.Scan() {
+		if scanner.Text() == field && scanner.Scan() {
+			return strings.TrimSpace(scanner.Text())
+		}
+	}
+	return ""
+}
+
+// descList returns the lines following a %FIELD% header up to the next blank line.
+func descList(desc []byte, field string) []string {
+	var values []string
+	scanner := bufio.NewScanner(bytes.NewReader(desc))
+	for scanner.Scan() {
+		if scanner.Text() != field {
+			continue
+		}
+		for scanner.Scan() && strings.TrimSpace(scanner.Text()) != "" {
+			values = append(values, strings.TrimSpace(scanner.Text()))
+		}
+		break
+	}
+	return values
+}
+
+// readNameList reads one package name per line, skipping # comments.
+func readNameList(path string, names map[string]bool) error {
+	r, closeFn, err := openMaybeGzip(path)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			names[line] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// openMaybeGzip opens path, transparently decompressing it if it is gzip.
+func openMaybeGzip(path string) (io.Reader, func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return gz, func() { gz.Close(); f.Close() }, nil
+	}
+	return br, func() { f.Close() }, nil
+}
@@ -0,0 +1,79 @@
+// FILE: internal/installer/resolve_test.go
+package installer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeSyncDB writes a gzipped sync database with one desc entry per package.
+func writeSyncDB(t *testing.T, path string, descs map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for dir, desc := range descs {
+		if err := tw.WriteHeader(&tar.Header{Name: dir + "/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: dir + "/desc", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(desc))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(desc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeSyncDB(t, filepath.Join(dir, "core.db"), map[string]string{
+		"bash-5.2.037-1": "%FILENAME%\nbash-5.2.037-1-x86_64.pkg.tar.zst\n\n%NAME%\nbash\n\n%VERSION%\n5.2.037-1\n\n" +
+			"%PROVIDES%\nsh\nlibreadline.so=8-64\n\n",
+		"gcc-14.2.1-1": "%NAME%\ngcc\n\n%GROUPS%\nbase-devel\n\n%DEPENDS%\nglibc\n\n",
+	})
+	writeSyncDB(t, filepath.Join(dir, "extra.db"), map[string]string{
+		"xorg-server-21.1.15-1": "%NAME%\nxorg-server\n\n%GROUPS%\nxorg\nxorg-drivers\n\n",
+	})
+	if err := os.WriteFile(filepath.Join(dir, "packages.txt"), []byte("# AUR\nyay\nparu-bin\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := SnapshotIndex(dir)
+	if err != nil {
+		t.Fatalf("SnapshotIndex: %v", err)
+	}
+
+	for _, name := range []string{"bash", "gcc", "xorg-server", "base-devel", "xorg", "xorg-drivers", "sh", "libreadline.so"} {
+		if !idx.Repo[name] {
+			t.Errorf("%s missing from the repo index", name)
+		}
+	}
+	for _, name := range []string{"glibc", "5.2.037-1", "bash-5.2.037-1-x86_64.pkg.tar.zst"} {
+		if idx.Repo[name] {
+			t.Errorf("%s is not a -S target but is in the repo index", name)
+		}
+	}
+
+	missing, err := idx.Unresolved([]string{"base-devel", "yay", "nonexistent", "bash", "sh", "xorg"})
+	if err != nil {
+		t.Fatalf("Unresolved: %v", err)
+	}
+	if want := []string{"nonexistent"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("unresolved = %q, want %q", missing, want)
+	}
+}
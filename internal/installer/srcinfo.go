@@ -0,0 +1,113 @@
+// FILE: internal/installer/srcinfo.go
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"runtime"
+	"strings"
+)
+
+// SrcInfo is what the native AUR builder needs from a .SRCINFO file.
+// Dependencies of split packages are merged into one list.
+type SrcInfo struct {
+	PkgBase      string
+	PkgVer       string
+	PkgRel       string
+	Epoch        string
+	Names        []string // pkgname entries
+	Depends      []string
+	MakeDepends  []string
+	CheckDepends []string
+	Provides     []string
+	Conflicts    []string
+	Replaces     []string
+}
+
+// Version returns the full [epoch:]pkgver-pkgrel string.
+func (s *SrcInfo) Version() string {
+	v := s.PkgVer + "-" + s.PkgRel
+	if s.Epoch != "" {
+		v = s.Epoch + ":" + v
+	}
+	return v
+}
+
+// pacmanArch maps GOARCH to the arch suffix used in .SRCINFO keys (depends_x86_64).
+func pacmanArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "386":
+		return "i686"
+	}
+	return runtime.GOARCH
+}
+
+// ParseSrcInfo reads the "key = value" format makepkg --printsrcinfo produces.
+func ParseSrcInfo(data []byte) *SrcInfo {
+	info := &SrcInfo{}
+	archSuffix := "_" + pacmanArch()
+	seen := make(map[string]map[string]bool)
+
+	add := func(list *[]string, key, value string) {
+		if seen[key] == nil {
+			seen[key] = make(map[string]bool)
+		}
+		if !seen[key][value] {
+			seen[key][value] = true
+			*list = append(*list, value)
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSuffix(strings.TrimSpace(key), archSuffix)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "pkgbase":
+			info.PkgBase = value
+		case "pkgname":
+			info.Names = append(info.Names, value)
+		case "pkgver":
+			info.PkgVer = value
+		case "pkgrel":
+			info.PkgRel = value
+		case "epoch":
+			info.Epoch = value
+		case "depends":
+			add(&info.Depends, key, value)
+		case "makedepends":
+			add(&info.MakeDepends, key, value)
+		case "checkdepends":
+			add(&info.CheckDepends, key, value)
+		case "provides":
+			add(&info.Provides, key, value)
+		case "conflicts":
+			add(&info.Conflicts, key, value)
+		case "replaces":
+			add(&info.Replaces, key, value)
+		}
+	}
+	return info
+}
+
+// depName strips a version constraint: "foo>=1.2" -> "foo".
+func depName(dep string) string {
+	if i := strings.IndexAny(dep, "<>="); i >= 0 {
+		return dep[:i]
+	}
+	return dep
+}
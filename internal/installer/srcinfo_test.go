@@ -0,0 +1,68 @@
+// FILE: internal/installer/srcinfo_test.go
+package installer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSrcInfo(t *testing.T) {
+	arch := pacmanArch()
+	other := "aarch64"
+	if arch == other {
+		other = "x86_64"
+	}
+
+	data := []byte(`# Generated by makepkg
+pkgbase = python-foo
+	pkgdesc = An example split package
+	pkgver = 2.1
+	pkgrel = 3
+	epoch = 1
+	arch = any
+	makedepends = python-build
+	makedepends = python-installer
+	checkdepends = python-pytest
+	depends = python
+	depends_` + arch + ` = glibc>=2.40
+	depends_` + other + ` = libother
+
+pkgname = python-foo
+	provides = foo=2.1
+	conflicts = foo-git
+	replaces = python-foo-old
+
+pkgname = python-foo-docs
+	depends = python
+`)
+
+	got := ParseSrcInfo(data)
+	want := &SrcInfo{
+		PkgBase:      "python-foo",
+		PkgVer:       "2.1",
+		PkgRel:       "3",
+		Epoch:        "1",
+		Names:        []string{"python-foo", "python-foo-docs"},
+		Depends:      []string{"python", "glibc>=2.40"},
+		MakeDepends:  []string{"python-build", "python-installer"},
+		CheckDepends: []string{"python-pytest"},
+		Provides:     []string{"foo=2.1"},
+		Conflicts:    []string{"foo-git"},
+		Replaces:     []string{"python-foo-old"},
+	}
+	// The other arch's depends aren't stripped of their suffix, so they don't count as depends
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSrcInfo =\n%+v\nwant\n%+v", got, want)
+	}
+	if v := got.Version(); v != "1:2.1-3" {
+		t.Errorf("Version() = %q, want 1:2.1-3", v)
+	}
+}
+
+func TestDepName(t *testing.T) {
+	for dep, want := range map[string]string{"glibc>=2.40": "glibc", "foo=2.1": "foo", "bar<3": "bar", "sh": "sh"} {
+		if got := depName(dep); got != want {
+			t.Errorf("depName(%q) = %q, want %q", dep, got, want)
+		}
+	}
+}
@@ -2,52 +2,248 @@
 package installer
 
 import (
-	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
 	"os/exec"
+	"strings"
 	"sync"
+	"time"
+
+	"guhwizard/internal/audit"
+	"guhwizard/internal/escalate"
+	"guhwizard/internal/privileged"
 )
 
-// Session manages the sudo session (now mostly a placeholder for passwordless)
+// keepAliveInterval refreshes the sudo timestamp well within sudo's default 5 minute timeout.
+const keepAliveInterval = time.Minute
+
+// ErrWrongPassword is returned when sudo rejects the password.
+var ErrWrongPassword = errors.New("the password was rejected")
+
+// wrongPasswordMessages are how sudo (with LC_ALL=C) says the password was
+// wrong, as opposed to other failures that also exit with 1.
+var wrongPasswordMessages = []string{"incorrect password attempt", "Sorry, try again."}
+
+// ErrNoHelper is returned by privileged operations when the privileged helper
+// isn't running and DirectEscalation isn't set.
+var ErrNoHelper = errors.New("the privileged helper is not running (use --without-helper to call the escalation backend directly)")
+
+// DirectEscalation lets RunSudo and WriteRootFile call the escalation backend
+// for every command instead of going through the helper (--without-helper).
+// Nothing then checks the commands against the allow-list, so it is opt-in.
+var DirectEscalation bool
+
+// Session keeps sudo credentials cached for the length of an install,
+// when no passwordless rule or privileged helper spares the password.
 type Session struct {
 	active bool
+	stop   chan struct{}
 	mu     sync.Mutex
 }
 
 var CurrentSession = &Session{}
 
-// ValidateSudo checks if sudo is working without a password using `sudo -n true`
+// ValidateSudo checks if the escalation backend works without a password (`sudo -n true`).
+// Running as root there is nothing to escalate.
 func ValidateSudo() error {
-	cmd := exec.Command("sudo", "-n", "true")
-	return cmd.Run()
+	if runningAsRoot() {
+		return nil
+	}
+	return escalate.Current().Validate()
 }
 
-func (s *Session) StartSudoKeepAlive(pwd string) error {
-	// No-op now as we use timestamp_timeout=-1
+// runningAsRoot reports whether guhwizard itself was started as root (sudo
+// ./guhwizard), privileged commands then run directly.
+func runningAsRoot() bool {
+	return geteuid() == 0
+}
+
+// geteuid is os.Geteuid, tests replace it to take the non-root paths.
+var geteuid = os.Geteuid
+
+// privilegeVia names how privileged commands outside the helper get root, for the audit log.
+func privilegeVia() string {
+	if runningAsRoot() {
+		return "root"
+	}
+	return escalate.Current().Name
+}
+
+// StartSudoKeepAlive authenticates with `sudo -S -v` and then refreshes the
+// timestamp until StopSudo. The password is only held in pwd and the stdin
+// buffer, both are wiped before this returns. Only sudo takes a password this
+// way (escalate.Backend.AcceptsPassword).
+func (s *Session) StartSudoKeepAlive(pwd []byte) error {
+	backend := escalate.Current()
+	if !backend.AcceptsPassword() {
+		wipe(pwd)
+		return fmt.Errorf("%s can't take the password from guhwizard", backend.Name)
+	}
+
+	input := make([]byte, len(pwd)+1)
+	copy(input, pwd)
+	input[len(pwd)] = '\n'
+	wipe(pwd)
+	defer wipe(input)
+
+	cmd := backend.AuthenticateCmd()
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		for _, msg := range wrongPasswordMessages {
+			if bytes.Contains(out, []byte(msg)) {
+				return ErrWrongPassword
+			}
+		}
+		return fmt.Errorf("%s failed: %s", backend.Name, strings.TrimSpace(string(out)))
+	}
+	s.KeepAlive()
 	return nil
 }
 
+// KeepAlive refreshes credentials that are already cached (an earlier sudo
+// on this terminal, the password from StartSudoKeepAlive) until StopSudo.
+// yay and paru call sudo themselves and need them for the whole install.
+// Backends that can't refresh (escalate.Backend.RefreshCmd) are left alone.
+func (s *Session) KeepAlive() {
+	backend := escalate.Current()
+	if runningAsRoot() || backend.RefreshCmd() == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active {
+		return
+	}
+	s.active = true
+	s.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(keepAliveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				backend.RefreshCmd().Run()
+			case <-stop:
+				return
+			}
+		}
+	}(s.stop)
+}
+
+// StopSudo ends the refresh and drops the cached credentials (sudo -k, doas -L).
 func (s *Session) StopSudo() {
-	// No-op
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.active {
+		return
+	}
+	close(s.stop)
+	s.active = false
+	if cmd := escalate.Current().InvalidateCmd(); cmd != nil {
+		cmd.Run()
+	}
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// helper is the root helper started by StartPrivilegedHelper. Without it,
+// RunSudo only calls sudo for every command with DirectEscalation.
+var helper *privileged.Client
+
+// StartPrivilegedHelper starts the root helper that RunSudo and WriteRootFile
+// go through. interactive lets sudo ask for the password on the terminal,
+// which only works before the TUI starts. With DirectEscalation it does nothing.
+func StartPrivilegedHelper(interactive bool) error {
+	if helper != nil || runningAsRoot() || DirectEscalation {
+		return nil
+	}
+	c, err := privileged.Start(interactive)
+	if err != nil {
+		return err
+	}
+	helper = c
+	return nil
 }
 
-// RunSudo executes a command with sudo privileges.
-// It assumes passwordless sudo is configured in /etc/sudoers.d/
+// StopPrivilegedHelper stops the helper, if it runs.
+func StopPrivilegedHelper() {
+	if helper != nil {
+		helper.Close()
+		helper = nil
+	}
+	// Its workspace is gone with it
+	if offline != nil {
+		offline.rootConf = ""
+	}
+}
+
+// HelperRunning reports whether privileged operations go through the helper.
+func HelperRunning() bool { return helper != nil }
+
+// RunSudo executes a command with root privileges, through the privileged
+// helper when it runs (only allow-listed commands). With DirectEscalation it
+// goes through sudo instead, which then needs passwordless sudo configured in
+// /etc/sudoers.d/ (or cached credentials of the escalation backend). Either
+// way it ends up in the audit log.
 func RunSudo(onLog func(string), command string, args ...string) error {
-	cmd := exec.Command("sudo", append([]string{command}, args...)...)
+	if helper != nil {
+		return runThroughHelper(command, args, onLog)
+	}
+	if !runningAsRoot() && !DirectEscalation {
+		return ErrNoHelper
+	}
 
-	pipe, _ := cmd.StdoutPipe()
-	cmd.Stderr = cmd.Stdout
+	argv := append([]string{command}, args...)
+	entry := audit.Begin("exec", argv, auditCause, currentUser(), privilegeVia())
+	var err error
+	if runningAsRoot() {
+		err = runLogged(exec.Command(command, args...), onLog)
+	} else {
+		// On the controlling terminal, where the credentials are cached. It
+		// has to fail rather than ask for a password nobody sees
+		err = runOnTerminal(escalate.Current().Command(true, nil, argv...), onLog)
+	}
+	entry.Finish(err)
+	recordPrivileged(entry, onLog)
+	return err
+}
+
+// WriteRootFile atomically replaces a root-owned file: the content is staged
+// next to the target with the final owner and mode, then renamed over it.
+func WriteRootFile(path string, content []byte, mode os.FileMode, log func(string)) error {
+	if helper != nil {
+		return helper.WriteFile(path, content, mode, auditCause)
+	}
+	if !runningAsRoot() && !DirectEscalation {
+		return ErrNoHelper
+	}
 
-	if err := cmd.Start(); err != nil {
+	tmp, err := os.CreateTemp("", "guhwizard-root-*")
+	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name())
 
-	scanner := bufio.NewScanner(pipe)
-	go func() {
-		for scanner.Scan() {
-			onLog(scanner.Text() + "\n")
-		}
-	}()
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
 
-	return cmd.Wait()
+	staged := path + ".guhwizard-new"
+	if err := RunSudo(log, "install", "-m", fmt.Sprintf("%04o", mode.Perm()), "-o", "root", "-g", "root", tmp.Name(), staged); err != nil {
+		return err
+	}
+	return RunSudo(log, "mv", "-f", staged, path)
 }
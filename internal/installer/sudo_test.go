@@ -0,0 +1,88 @@
+// FILE: internal/installer/sudo_test.go
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"guhwizard/internal/config"
+	"guhwizard/internal/escalate"
+)
+
+// fakeSudo prints the session it runs in instead of escalating.
+const fakeSudo = `#!/bin/sh
+read -r _ _ _ _ _ sid _ < /proc/$$/stat
+echo "session $sid"
+`
+
+// logLines collects what a command logs, which may come from another goroutine.
+type logLines struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *logLines) log(s string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, strings.TrimRight(s, "\n"))
+}
+
+func (l *logLines) contains(line string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, got := range l.lines {
+		if got == line {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionID is field 6 of /proc/self/stat.
+func sessionID(t *testing.T) string {
+	data, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		t.Skip(err)
+	}
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	return fields[3]
+}
+
+// Commands going through the escalation backend have to stay in our terminal
+// session, sudo only reuses the credentials cached for it.
+func TestEscalatedCommandsKeepTheSession(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(fakeSudo), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	if err := escalate.Select("sudo"); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(prev func() int, direct bool) { geteuid, DirectEscalation = prev, direct }(geteuid, DirectEscalation)
+	geteuid = func() int { return 1000 }
+	DirectEscalation = true
+
+	want := "session " + sessionID(t)
+	commands := map[string]func(log func(string)) error{
+		"RunSudo": func(log func(string)) error { return RunSudo(log, "true") },
+		"root hook": func(log func(string)) error {
+			return runHook(config.Hook{Command: "true", RunAs: "root"}, nil, log)
+		},
+	}
+	for name, run := range commands {
+		var out logLines
+		if err := run(out.log); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !out.contains(want) {
+			t.Errorf("%s ran in another session: %q, want %q", name, out.lines, want)
+		}
+	}
+}
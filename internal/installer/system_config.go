@@ -10,36 +10,39 @@ import (
 
 	"guhwizard/internal/config"
 	"guhwizard/internal/fs"
+	"guhwizard/internal/target"
 )
 
+// SilentSDDMRepo is the SDDM theme installed by ConfigureSDDM.
+const SilentSDDMRepo = "https://github.com/uiriansan/SilentSDDM"
+
+// sddmDeps are the Qt modules the SilentSDDM theme needs.
+var sddmDeps = []string{"qt6-svg", "qt6-virtualkeyboard", "qt6-multimedia-ffmpeg"}
+
 func ConfigureSDDM(cfg *config.Config, log func(string)) error {
 	log("Installing SDDM Theme dependencies...\n")
-	deps := []string{"qt6-svg", "qt6-virtualkeyboard", "qt6-multimedia-ffmpeg"}
-
 	// Install deps
-	// We append the deps to the args list safely
-	pacArgs := []string{"-S", "--needed", "--noconfirm"}
-	pacArgs = append(pacArgs, deps...)
-	if err := RunSudo(log, "pacman", pacArgs...); err != nil {
+	if err := (&Pacman{}).Install(sddmDeps, log); err != nil {
 		return fmt.Errorf("failed to install sddm deps: %w", err)
 	}
 
-	home, _ := os.UserHomeDir()
-	tempDir := filepath.Join(home, "Downloads", "SilentSDDM_Setup")
-	os.RemoveAll(tempDir)
+	tempDir := filepath.Join(target.Current().Home, "Downloads", "SilentSDDM_Setup")
+	if err := fs.TargetRemoveAll(tempDir); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", tempDir, err)
+	}
 
 	log("Cloning SilentSDDM theme...\n")
-	if err := exec.Command("git", "clone", "https://github.com/uiriansan/SilentSDDM", tempDir).Run(); err != nil {
+	if err := gitClone(SilentSDDMRepo, tempDir); err != nil {
 		return fmt.Errorf("failed to clone theme repo: %w", err)
 	}
 
 	log("Installing Theme Files...\n")
 	RunSudo(log, "mkdir", "-p", "/usr/share/sddm/themes/silent")
-	RunSudo(log, "sh", "-c", fmt.Sprintf("cp -rf %s/. /usr/share/sddm/themes/silent/", tempDir))
+	RunSudo(log, "cp", "-rfT", tempDir, "/usr/share/sddm/themes/silent")
 
 	log("Installing Fonts...\n")
 	RunSudo(log, "mkdir", "-p", "/usr/share/fonts")
-	RunSudo(log, "sh", "-c", fmt.Sprintf("cp -r %s/fonts/* /usr/share/fonts/", tempDir))
+	RunSudo(log, "cp", "-rT", filepath.Join(tempDir, "fonts"), "/usr/share/fonts")
 
 	log("Patching /etc/sddm.conf...\n")
 	// Safe Backup manually via sudo since it's root owned
@@ -52,14 +55,9 @@ Current=silent
 InputMethod=qtvirtualkeyboard
 GreeterEnvironment=QML2_IMPORT_PATH=/usr/share/sddm/themes/silent/components/,QT_IM_MODULE=qtvirtualkeyboard
 `
-	// Write temp file then sudo move it
-	tmpConfig := filepath.Join(os.TempDir(), "sddm_patch.conf")
-	if err := os.WriteFile(tmpConfig, []byte(configBlock), 0644); err != nil {
-		return err
-	}
 
 	log("Writing SDDM config...\n")
-	if err := RunSudo(log, "sh", "-c", fmt.Sprintf("cat %s | tee /etc/sddm.conf", tmpConfig)); err != nil {
+	if err := WriteRootFile("/etc/sddm.conf", []byte(configBlock), 0644, log); err != nil {
 		return fmt.Errorf("failed to write sddm config: %w", err)
 	}
 
@@ -70,25 +68,45 @@ GreeterEnvironment=QML2_IMPORT_PATH=/usr/share/sddm/themes/silent/components/,QT
 func PatchTerminal(selectedTerminal string, log func(string)) error {
 	log(fmt.Sprintf("Patching default terminal to %s...\n", selectedTerminal))
 
-	home, _ := os.UserHomeDir()
-	configPath := filepath.Join(home, ".config/mangowc/config.conf")
-
-	// Expand path just in case
-	configPath, _ = fs.ExpandHome(configPath)
+	configPath := filepath.Join(target.Current().Home, ".config/mangowc/config.conf")
 
-	input, err := os.ReadFile(configPath)
+	input, err := fs.TargetReadFile(configPath)
 	if err != nil {
 		log(fmt.Sprintf("Warning: Config file %s not found, skipping patch.\n", configPath))
 		return nil // Not fatal
 	}
 
 	// Naive replace
-	target := "bind=ALT, Return, spawn, foot"
+	original := "bind=ALT, Return, spawn, foot"
 	replacement := fmt.Sprintf("bind=ALT, Return, spawn, %s", selectedTerminal)
-	output := strings.Replace(string(input), target, replacement, 1)
+	output := strings.Replace(string(input), original, replacement, 1)
 
-	// Use SafeFS for atomic write
-	return fs.AtomicWrite(configPath, []byte(output), 0644)
+	// Use SafeFS for atomic write, as the user
+	return fs.TargetWriteFile(configPath, []byte(output), 0644)
+}
+
+// EnableUserServices enables the blueprint's user units for the target user,
+// running systemctl --user as them. Without a session of theirs there is no
+// user manager to talk to, the unit symlinks are then created directly.
+func EnableUserServices(cfg *config.Config, log func(string)) error {
+	units := cfg.Settings.UserServices
+	if len(units) == 0 {
+		return nil
+	}
+	u := target.Current()
+	log(fmt.Sprintf("Enabling user services for %s...\n", u.Name))
+
+	cmd := target.Command("systemctl", append([]string{"--user", "enable"}, units...)...)
+	if u.RuntimeDir() == "" {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, "SYSTEMCTL_INSTALL_CLIENT_SIDE=1")
+	}
+	if err := runLogged(cmd, log); err != nil {
+		return fmt.Errorf("failed to enable user services: %w", err)
+	}
+	return nil
 }
 
 func ChangeShell(shellName string, log func(string)) error {
@@ -101,6 +119,5 @@ func ChangeShell(shellName string, log func(string)) error {
 	}
 	shellPath := strings.TrimSpace(string(out))
 
-	user := os.Getenv("USER")
-	return RunSudo(log, "chsh", "-s", shellPath, user)
+	return RunSudo(log, "chsh", "-s", shellPath, target.Current().Name)
 }
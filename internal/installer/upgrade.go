@@ -0,0 +1,139 @@
+// FILE: internal/installer/upgrade.go
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"guhwizard/internal/config"
+)
+
+// syncDBDir holds the sync databases pacman -Sy downloads.
+const syncDBDir = "/var/lib/pacman/sync"
+
+// staleSyncDB is how old the sync databases may get before installs from them
+// start failing with 404s as mirrors drop the versions they list.
+const staleSyncDB = 3 * 24 * time.Hour
+
+// UpgradeSummary describes the upgrade waiting in the current sync databases.
+type UpgradeSummary struct {
+	Packages     int
+	DownloadSize int64
+	// SyncAge is how long ago the sync databases were refreshed
+	SyncAge time.Duration
+	Stale   bool
+}
+
+// PendingUpgrade reads the pending upgrade from the sync databases as they are,
+// without refreshing them (that needs root). A stale database undercounts.
+func PendingUpgrade() (*UpgradeSummary, error) {
+	age, err := syncDBAge()
+	if err != nil {
+		return nil, err
+	}
+	summary := &UpgradeSummary{SyncAge: age, Stale: age > staleSyncDB}
+
+	// -Sup prints what -Su would download, one package per line
+	out, err := pacmanQuery("-Sup", "--print-format", "%n %s").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending upgrades: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		summary.Packages++
+		if size, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			summary.DownloadSize += size
+		}
+	}
+	return summary, nil
+}
+
+// syncDBAge returns the age of the oldest sync database. The offline repo
+// is synced at the start of every offline install, so it never counts as stale.
+func syncDBAge() (time.Duration, error) {
+	if offline != nil {
+		return 0, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(syncDBDir, "*.db"))
+	if err != nil || len(matches) == 0 {
+		return 0, fmt.Errorf("no sync databases in %s, run pacman -Sy", syncDBDir)
+	}
+
+	var oldest time.Time
+	for _, path := range matches {
+		st, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		if oldest.IsZero() || st.ModTime().Before(oldest) {
+			oldest = st.ModTime()
+		}
+	}
+	return time.Since(oldest), nil
+}
+
+// UpgradeSystem refreshes the sync databases and upgrades the whole system
+// before anything gets installed, so new packages never link against older
+// libraries. archlinux-keyring goes first, an outdated keyring makes -Su fail
+// on packages signed by new packager keys.
+func UpgradeSystem(cfg *config.Config, log func(string)) error {
+	if cfg.Settings.SkipSystemUpgrade {
+		log("Skipping the system upgrade (skip_system_upgrade).\n")
+		if age, err := syncDBAge(); err == nil && age > staleSyncDB {
+			log(fmt.Sprintf("Warning: the sync databases are %s old, packages may fail to download or mismatch installed libraries.\n", FormatAge(age)))
+		}
+		return nil
+	}
+
+	if offline != nil {
+		// The bundle only carries what the blueprint needs, not a full system
+		log("Skipping the system upgrade for the offline install.\n")
+		return nil
+	}
+
+	log("Refreshing archlinux-keyring...\n")
+	if err := runPacman(log, "-Sy", "--needed", "--noconfirm", "archlinux-keyring"); err != nil {
+		return fmt.Errorf("failed to refresh archlinux-keyring: %w", err)
+	}
+
+	log("Upgrading the system...\n")
+	if err := runPacman(log, "-Su", "--noconfirm"); err != nil {
+		return fmt.Errorf("system upgrade failed: %w", err)
+	}
+	return nil
+}
+
+// FormatSize renders a byte count the way pacman does (KiB, MiB, GiB).
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// FormatAge renders a sync database age in whole days or hours.
+func FormatAge(d time.Duration) string {
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	}
+	if d >= 2*time.Hour {
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	}
+	return "less than 2 hours"
+}
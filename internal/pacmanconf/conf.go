@@ -0,0 +1,205 @@
+// FILE: internal/pacmanconf/conf.go
+package pacmanconf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// File is pacman.conf as a list of lines. Edits touch only the lines they
+// need, so comments, blank lines and ordering survive a round trip.
+type File struct {
+	lines []string
+}
+
+// Repo is a repository section to add.
+type Repo struct {
+	Name     string   `yaml:"name"`
+	SigLevel string   `yaml:"sig_level"`
+	Servers  []string `yaml:"servers"`
+	Include  string   `yaml:"include"` // e.g. /etc/pacman.d/chaotic-mirrorlist
+}
+
+// Parse reads pacman.conf content.
+func Parse(content string) *File {
+	content = strings.TrimSuffix(content, "\n")
+	if content == "" {
+		return &File{}
+	}
+	return &File{lines: strings.Split(content, "\n")}
+}
+
+// String renders the file back, with a trailing newline.
+func (f *File) String() string {
+	if len(f.lines) == 0 {
+		return ""
+	}
+	return strings.Join(f.lines, "\n") + "\n"
+}
+
+// sectionName returns the section a header line opens, also for commented-out headers.
+func sectionName(line string) (name string, commented bool, ok bool) {
+	t := strings.TrimSpace(line)
+	if strings.HasPrefix(t, "#") {
+		commented = true
+		t = strings.TrimSpace(strings.TrimLeft(t, "#"))
+	}
+	if strings.HasPrefix(t, "[") && strings.HasSuffix(t, "]") && !strings.Contains(t, " ") {
+		return t[1 : len(t)-1], commented, true
+	}
+	return "", false, false
+}
+
+// directive parses "Key = Value" or "Key", optionally commented out.
+func directive(line string) (key string, commented bool, ok bool) {
+	t := strings.TrimSpace(line)
+	if strings.HasPrefix(t, "#") {
+		commented = true
+		t = strings.TrimSpace(strings.TrimPrefix(t, "#"))
+	}
+	if t == "" || strings.HasPrefix(t, "[") {
+		return "", false, false
+	}
+	k, _, _ := strings.Cut(t, "=")
+	k = strings.TrimSpace(k)
+	// Prose comments have spaces in them, directive names don't
+	if k == "" || strings.ContainsAny(k, " \t") {
+		return "", false, false
+	}
+	return k, commented, true
+}
+
+// section returns the [start, end) line range of an active section, header included.
+func (f *File) section(name string) (int, int, bool) {
+	for i, line := range f.lines {
+		n, commented, ok := sectionName(line)
+		if !ok || commented || n != name {
+			continue
+		}
+		end := len(f.lines)
+		for j := i + 1; j < len(f.lines); j++ {
+			if _, c, ok := sectionName(f.lines[j]); ok && !c {
+				end = j
+				break
+			}
+		}
+		return i, end, true
+	}
+	return 0, 0, false
+}
+
+// HasRepo reports whether an active [name] section exists.
+func (f *File) HasRepo(name string) bool {
+	_, _, ok := f.section(name)
+	return ok
+}
+
+// SetOption sets "Key = value" in [options]. An existing line (active or commented
+// out) is replaced in place, otherwise the option is added after the last one.
+func (f *File) SetOption(key, value string) error {
+	return f.setOption(key, fmt.Sprintf("%s = %s", key, value))
+}
+
+// SetFlag enables a value-less option such as Color or ILoveCandy.
+func (f *File) SetFlag(key string) error {
+	return f.setOption(key, key)
+}
+
+func (f *File) setOption(key, line string) error {
+	start, end, ok := f.section("options")
+	if !ok {
+		return fmt.Errorf("no [options] section")
+	}
+
+	commentedAt, lastDirective := -1, start
+	for i := start + 1; i < end; i++ {
+		k, commented, ok := directive(f.lines[i])
+		if !ok {
+			continue
+		}
+		if k == key && !commented {
+			f.lines[i] = line
+			return nil
+		}
+		if k == key && commentedAt < 0 {
+			commentedAt = i
+		}
+		if !commented {
+			lastDirective = i
+		}
+	}
+
+	if commentedAt >= 0 {
+		f.lines[commentedAt] = line
+		return nil
+	}
+	f.insert(lastDirective+1, line)
+	return nil
+}
+
+// EnableRepo activates a repo section. A commented-out one (like the stock
+// #[multilib] block) is uncommented together with its directives; a missing
+// one is added using the standard mirrorlist.
+func (f *File) EnableRepo(name string) {
+	if f.HasRepo(name) {
+		return
+	}
+
+	for i, line := range f.lines {
+		n, commented, ok := sectionName(line)
+		if !ok || !commented || n != name {
+			continue
+		}
+		f.lines[i] = "[" + name + "]"
+		for j := i + 1; j < len(f.lines); j++ {
+			if _, _, isSection := sectionName(f.lines[j]); isSection {
+				break
+			}
+			_, commented, ok := directive(f.lines[j])
+			if !ok || !commented {
+				break
+			}
+			f.lines[j] = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(f.lines[j]), "#"))
+		}
+		return
+	}
+
+	f.AddRepo(Repo{Name: name, Include: "/etc/pacman.d/mirrorlist"})
+}
+
+// AddRepo appends a repository section, or replaces the body of an existing one.
+func (f *File) AddRepo(repo Repo) {
+	body := []string{"[" + repo.Name + "]"}
+	if repo.SigLevel != "" {
+		body = append(body, "SigLevel = "+repo.SigLevel)
+	}
+	for _, s := range repo.Servers {
+		body = append(body, "Server = "+s)
+	}
+	if repo.Include != "" {
+		body = append(body, "Include = "+repo.Include)
+	}
+
+	if start, end, ok := f.section(repo.Name); ok {
+		// Keep trailing blank lines and comments that belong to whatever follows
+		for end > start+1 {
+			if _, _, ok := directive(f.lines[end-1]); ok {
+				break
+			}
+			end--
+		}
+		f.lines = append(f.lines[:start], append(body, f.lines[end:]...)...)
+		return
+	}
+
+	if n := len(f.lines); n > 0 && strings.TrimSpace(f.lines[n-1]) != "" {
+		f.lines = append(f.lines, "")
+	}
+	f.lines = append(f.lines, body...)
+}
+
+func (f *File) insert(at int, line string) {
+	f.lines = append(f.lines, "")
+	copy(f.lines[at+1:], f.lines[at:])
+	f.lines[at] = line
+}
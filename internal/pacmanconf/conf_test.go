@@ -0,0 +1,213 @@
+// FILE: internal/pacmanconf/conf_test.go
+package pacmanconf
+
+import "testing"
+
+// stock is the part of Arch's default pacman.conf the edits care about.
+const stock = `#
+# /etc/pacman.conf
+#
+[options]
+#RootDir     = /
+HoldPkg     = pacman glibc
+Architecture = auto
+
+# Misc options
+#UseSyslog
+#Color
+#NoProgressBar
+CheckSpace
+#VerbosePkgLists
+#ParallelDownloads = 5
+DownloadUser = alpm
+
+SigLevel    = Required DatabaseOptional
+LocalFileSigLevel = Optional
+
+[core]
+Include = /etc/pacman.d/mirrorlist
+
+[extra]
+Include = /etc/pacman.d/mirrorlist
+
+#[multilib-testing]
+#Include = /etc/pacman.d/mirrorlist
+
+#[multilib]
+#Include = /etc/pacman.d/mirrorlist
+`
+
+func TestRoundTrip(t *testing.T) {
+	for name, content := range map[string]string{
+		"stock":                stock,
+		"empty":                "",
+		"trailing blank lines": "[options]\nColor\n\n\n",
+		"indented comments":    "  # comment\n[options]\n\t#Color\n",
+	} {
+		if got := Parse(content).String(); got != content {
+			t.Errorf("%s: round trip changed the file:\n%q\nwant\n%q", name, got, content)
+		}
+	}
+}
+
+func TestEnableRepo(t *testing.T) {
+	tests := []struct {
+		name, before, after string
+	}{
+		{
+			name:   "commented out",
+			before: "[core]\nInclude = /etc/pacman.d/mirrorlist\n\n#[multilib-testing]\n#Include = /etc/pacman.d/mirrorlist\n\n#[multilib]\n#Include = /etc/pacman.d/mirrorlist\n",
+			after:  "[core]\nInclude = /etc/pacman.d/mirrorlist\n\n#[multilib-testing]\n#Include = /etc/pacman.d/mirrorlist\n\n[multilib]\nInclude = /etc/pacman.d/mirrorlist\n",
+		},
+		{
+			name:   "commented out with a following comment",
+			before: "#[multilib]\n#Include = /etc/pacman.d/mirrorlist\n\n# An example of a custom package repository.\n",
+			after:  "[multilib]\nInclude = /etc/pacman.d/mirrorlist\n\n# An example of a custom package repository.\n",
+		},
+		{
+			name:   "missing",
+			before: "[core]\nInclude = /etc/pacman.d/mirrorlist\n",
+			after:  "[core]\nInclude = /etc/pacman.d/mirrorlist\n\n[multilib]\nInclude = /etc/pacman.d/mirrorlist\n",
+		},
+		{
+			name:   "already enabled",
+			before: "[multilib]\nInclude = /etc/pacman.d/mirrorlist\n\n#[multilib]\n#Server = https://example.org\n",
+			after:  "[multilib]\nInclude = /etc/pacman.d/mirrorlist\n\n#[multilib]\n#Server = https://example.org\n",
+		},
+	}
+	for _, tt := range tests {
+		f := Parse(tt.before)
+		f.EnableRepo("multilib")
+		if got := f.String(); got != tt.after {
+			t.Errorf("%s:\n%s\nwant\n%s", tt.name, got, tt.after)
+		}
+		if !f.HasRepo("multilib") {
+			t.Errorf("%s: multilib not active afterwards", tt.name)
+		}
+	}
+}
+
+func TestSetOption(t *testing.T) {
+	tests := []struct {
+		name, before, after string
+		set                 func(*File) error
+	}{
+		{
+			name:   "replaces the active line",
+			before: "[options]\nParallelDownloads = 3\nCheckSpace\n",
+			after:  "[options]\nParallelDownloads = 8\nCheckSpace\n",
+			set:    func(f *File) error { return f.SetOption("ParallelDownloads", "8") },
+		},
+		{
+			name:   "uncomments in place",
+			before: "[options]\n#ParallelDownloads = 5\nCheckSpace\n",
+			after:  "[options]\nParallelDownloads = 5\nCheckSpace\n",
+			set:    func(f *File) error { return f.SetOption("ParallelDownloads", "5") },
+		},
+		{
+			name:   "active line wins over a commented one",
+			before: "[options]\n#ParallelDownloads = 5\nParallelDownloads = 2\n",
+			after:  "[options]\n#ParallelDownloads = 5\nParallelDownloads = 10\n",
+			set:    func(f *File) error { return f.SetOption("ParallelDownloads", "10") },
+		},
+		{
+			name:   "appends after the last option",
+			before: "[options]\nHoldPkg = pacman\n\n# Misc options\nCheckSpace\n\n[core]\nInclude = /etc/pacman.d/mirrorlist\n",
+			after:  "[options]\nHoldPkg = pacman\n\n# Misc options\nCheckSpace\nParallelDownloads = 5\n\n[core]\nInclude = /etc/pacman.d/mirrorlist\n",
+			set:    func(f *File) error { return f.SetOption("ParallelDownloads", "5") },
+		},
+		{
+			name:   "only in [options]",
+			before: "[options]\nCheckSpace\n\n[custom]\n#Color\n",
+			after:  "[options]\nCheckSpace\nColor\n\n[custom]\n#Color\n",
+			set:    func(f *File) error { return f.SetFlag("Color") },
+		},
+		{
+			name:   "flag uncommented",
+			before: "[options]\n#UseSyslog\n#Color\n",
+			after:  "[options]\n#UseSyslog\nColor\n",
+			set:    func(f *File) error { return f.SetFlag("Color") },
+		},
+		{
+			name:   "prose comments aren't options",
+			before: "[options]\n# Color the output\nCheckSpace\n",
+			after:  "[options]\n# Color the output\nCheckSpace\nColor\n",
+			set:    func(f *File) error { return f.SetFlag("Color") },
+		},
+	}
+	for _, tt := range tests {
+		f := Parse(tt.before)
+		if err := tt.set(f); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := f.String(); got != tt.after {
+			t.Errorf("%s:\n%s\nwant\n%s", tt.name, got, tt.after)
+		}
+	}
+
+	if err := Parse("[core]\nInclude = /etc/pacman.d/mirrorlist\n").SetFlag("Color"); err == nil {
+		t.Error("SetFlag without [options]: want an error")
+	}
+}
+
+func TestAddRepo(t *testing.T) {
+	repo := Repo{Name: "chaotic-aur", Include: "/etc/pacman.d/chaotic-mirrorlist"}
+
+	f := Parse("[extra]\nInclude = /etc/pacman.d/mirrorlist\n")
+	f.AddRepo(repo)
+	want := "[extra]\nInclude = /etc/pacman.d/mirrorlist\n\n[chaotic-aur]\nInclude = /etc/pacman.d/chaotic-mirrorlist\n"
+	if got := f.String(); got != want {
+		t.Errorf("add:\n%s\nwant\n%s", got, want)
+	}
+
+	// Adding it again replaces the body but keeps what follows
+	f = Parse("[chaotic-aur]\nServer = https://old.example.org\n\n# local repo\n[local]\nServer = file:///srv\n")
+	f.AddRepo(repo)
+	want = "[chaotic-aur]\nInclude = /etc/pacman.d/chaotic-mirrorlist\n\n# local repo\n[local]\nServer = file:///srv\n"
+	if got := f.String(); got != want {
+		t.Errorf("replace:\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStockEdits(t *testing.T) {
+	f := Parse(stock)
+	f.EnableRepo("multilib")
+	if err := f.SetOption("ParallelDownloads", "5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetFlag("Color"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetFlag("ILoveCandy"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `  # Misc options
+  #UseSyslog
+- #Color
++ Color
+  #NoProgressBar
+  CheckSpace
+  #VerbosePkgLists
+- #ParallelDownloads = 5
++ ParallelDownloads = 5
+  DownloadUser = alpm
+` + "  " + `
+  SigLevel    = Required DatabaseOptional
+  LocalFileSigLevel = Optional
++ ILoveCandy
+` + "  " + `
+  [core]
+...
+  #Include = /etc/pacman.d/mirrorlist
+` + "  " + `
+- #[multilib]
+- #Include = /etc/pacman.d/mirrorlist
++ [multilib]
++ Include = /etc/pacman.d/mirrorlist
+`
+	if got := Diff(stock, f.String()); got != want {
+		t.Errorf("diff:\n%s\nwant\n%s", got, want)
+	}
+}
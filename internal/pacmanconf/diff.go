@@ -0,0 +1,81 @@
+// FILE: internal/pacmanconf/diff.go
+package pacmanconf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is how many unchanged lines to show around each change.
+const diffContext = 2
+
+// Diff returns a unified-style line diff between two versions of a file,
+// or "" when they are identical.
+func Diff(oldText, newText string) string {
+	a := strings.Split(strings.TrimSuffix(oldText, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(newText, "\n"), "\n")
+
+	// Longest common subsequence table, fine for files the size of pacman.conf
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type op struct {
+		kind byte // ' ', '-', '+'
+		line string
+	}
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+
+	// Only print changes and their surrounding context
+	show := make([]bool, len(ops))
+	changed := false
+	for k, o := range ops {
+		if o.kind == ' ' {
+			continue
+		}
+		changed = true
+		for c := max(0, k-diffContext); c <= min(len(ops)-1, k+diffContext); c++ {
+			show[c] = true
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var out strings.Builder
+	for k, o := range ops {
+		if !show[k] {
+			if k > 0 && show[k-1] {
+				out.WriteString("...\n")
+			}
+			continue
+		}
+		fmt.Fprintf(&out, "%c %s\n", o.kind, o.line)
+	}
+	return out.String()
+}
@@ -0,0 +1,46 @@
+// FILE: internal/pacmanconf/diff_test.go
+package pacmanconf
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name, old, new, want string
+	}{
+		{
+			name: "identical",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "changed line",
+			old:  "a\nb\nc\n",
+			new:  "a\nB\nc\n",
+			want: "  a\n- b\n+ B\n  c\n",
+		},
+		{
+			name: "added at the end",
+			old:  "a\n",
+			new:  "a\n\n[multilib]\n",
+			want: "  a\n+ \n+ [multilib]\n",
+		},
+		{
+			name: "context is cut between distant changes",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\n8\nnine\n",
+			want: "- 1\n+ one\n  2\n  3\n...\n  7\n  8\n- 9\n+ nine\n",
+		},
+		{
+			name: "missing trailing newline is no change",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		if got := Diff(tt.old, tt.new); got != tt.want {
+			t.Errorf("%s:\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// FILE: internal/pacmanconf/edits.go
+package pacmanconf
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Path is the system pacman.conf.
+	Path = "/etc/pacman.conf"
+	// BackupPath holds pacman.conf from before guhwizard first changed it.
+	BackupPath = "/etc/pacman.conf.guhwizard.bak"
+)
+
+// maxParallelDownloads bounds ParallelDownloads to something mirrors tolerate.
+const maxParallelDownloads = 20
+
+var (
+	// repoNamePattern is what pacman accepts in a section header, minus anything odd
+	repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+	// officialRepos can't be redefined: their packages would come from another server
+	officialRepos = map[string]bool{
+		"options": true, "core": true, "extra": true, "multilib": true,
+		"core-testing": true, "extra-testing": true, "multilib-testing": true,
+		"gnome-unstable": true, "kde-unstable": true,
+	}
+
+	// enableableRepos are the stock sections EnableRepo may uncomment
+	enableableRepos = map[string]bool{"multilib": true}
+
+	// editableFlags are the value-less options the pacman step sets
+	editableFlags = map[string]bool{"Color": true, "ILoveCandy": true, "VerbosePkgLists": true}
+
+	// weakSigLevels would let packages in without a valid signature
+	weakSigLevels = map[string]bool{"Never": true, "Optional": true, "PackageNever": true, "PackageOptional": true}
+)
+
+// Edits are the changes guhwizard makes to pacman.conf. The privileged helper
+// applies them to the file itself (after Check) rather than taking a whole
+// new file from the installer.
+type Edits struct {
+	EnableRepos       []string
+	ParallelDownloads int // 0 leaves it alone
+	Flags             []string
+	Repos             []Repo
+}
+
+// Apply makes the edits to f.
+func (e *Edits) Apply(f *File) error {
+	for _, name := range e.EnableRepos {
+		f.EnableRepo(name)
+	}
+	if e.ParallelDownloads > 0 {
+		if err := f.SetOption("ParallelDownloads", strconv.Itoa(e.ParallelDownloads)); err != nil {
+			return err
+		}
+	}
+	for _, flag := range e.Flags {
+		if err := f.SetFlag(flag); err != nil {
+			return err
+		}
+	}
+	for _, repo := range e.Repos {
+		f.AddRepo(repo)
+	}
+	return nil
+}
+
+// Check refuses edits beyond tuning pacman: only multilib can be enabled and
+// only a few harmless options set, and added repositories must keep package
+// signatures required (see Repo.Check).
+func (e *Edits) Check() error {
+	for _, name := range e.EnableRepos {
+		if !enableableRepos[name] {
+			return fmt.Errorf("enabling [%s]", name)
+		}
+	}
+	if e.ParallelDownloads < 0 || e.ParallelDownloads > maxParallelDownloads {
+		return fmt.Errorf("ParallelDownloads = %d (at most %d)", e.ParallelDownloads, maxParallelDownloads)
+	}
+	for _, flag := range e.Flags {
+		if !editableFlags[flag] {
+			return fmt.Errorf("option %s", flag)
+		}
+	}
+	for _, repo := range e.Repos {
+		if err := repo.Check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check refuses a repository that would weaken pacman: a name of one of the
+// official repositories or an odd one, a SigLevel accepting unsigned
+// packages, servers other than https:// and an Include outside /etc/pacman.d.
+// Values can't break out of their line.
+func (r Repo) Check() error {
+	if !repoNamePattern.MatchString(r.Name) || officialRepos[r.Name] {
+		return fmt.Errorf("repository name %q", r.Name)
+	}
+	for _, level := range strings.Fields(r.SigLevel) {
+		if weakSigLevels[level] {
+			return fmt.Errorf("[%s]: SigLevel %s accepts unsigned packages", r.Name, level)
+		}
+	}
+	values := append([]string{r.SigLevel, r.Include}, r.Servers...)
+	for _, v := range values {
+		if strings.ContainsFunc(v, func(c rune) bool { return c < ' ' || c == 0x7f }) {
+			return fmt.Errorf("[%s]: control character in %q", r.Name, v)
+		}
+	}
+	for _, s := range r.Servers {
+		if !strings.HasPrefix(s, "https://") || strings.ContainsAny(s, " \t") {
+			return fmt.Errorf("[%s]: server %q is not an https:// URL", r.Name, s)
+		}
+	}
+	if r.Include != "" && (filepath.Clean(r.Include) != r.Include || !strings.HasPrefix(r.Include, "/etc/pacman.d/")) {
+		return fmt.Errorf("[%s]: Include %s is not a file in /etc/pacman.d", r.Name, r.Include)
+	}
+	return nil
+}
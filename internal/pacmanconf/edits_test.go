@@ -0,0 +1,50 @@
+// FILE: internal/pacmanconf/edits_test.go
+package pacmanconf
+
+import "testing"
+
+func TestEditsApply(t *testing.T) {
+	f := Parse("[options]\n#Color\n\n#[multilib]\n#Include = /etc/pacman.d/mirrorlist\n")
+	e := &Edits{
+		EnableRepos:       []string{"multilib"},
+		ParallelDownloads: 5,
+		Flags:             []string{"Color"},
+		Repos:             []Repo{{Name: "chaotic-aur", Include: "/etc/pacman.d/chaotic-mirrorlist"}},
+	}
+	if err := e.Apply(f); err != nil {
+		t.Fatal(err)
+	}
+	want := "[options]\nParallelDownloads = 5\nColor\n\n[multilib]\nInclude = /etc/pacman.d/mirrorlist\n\n[chaotic-aur]\nInclude = /etc/pacman.d/chaotic-mirrorlist\n"
+	if got := f.String(); got != want {
+		t.Errorf("Apply:\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEditsCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		edits Edits
+		ok    bool
+	}{
+		{"stock tuning", Edits{EnableRepos: []string{"multilib"}, ParallelDownloads: 5, Flags: []string{"Color", "ILoveCandy"}}, true},
+		{"third-party repo", Edits{Repos: []Repo{{Name: "chaotic-aur", Include: "/etc/pacman.d/chaotic-mirrorlist"}}}, true},
+		{"signed repo", Edits{Repos: []Repo{{Name: "myrepo", SigLevel: "Required DatabaseOptional", Servers: []string{"https://repo.example.com/$arch"}}}}, true},
+
+		{"testing repo", Edits{EnableRepos: []string{"core-testing"}}, false},
+		{"HookDir", Edits{Flags: []string{"HookDir"}}, false},
+		{"too many downloads", Edits{ParallelDownloads: 500}, false},
+		{"SigLevel Never", Edits{Repos: []Repo{{Name: "myrepo", SigLevel: "Never", Servers: []string{"https://repo.example.com"}}}}, false},
+		{"unsigned packages", Edits{Repos: []Repo{{Name: "myrepo", SigLevel: "Optional TrustAll", Servers: []string{"https://repo.example.com"}}}}, false},
+		{"redefined core", Edits{Repos: []Repo{{Name: "core", Servers: []string{"https://evil.example.com"}}}}, false},
+		{"options section", Edits{Repos: []Repo{{Name: "options", Include: "/etc/pacman.d/x"}}}, false},
+		{"plain http", Edits{Repos: []Repo{{Name: "myrepo", Servers: []string{"http://repo.example.com"}}}}, false},
+		{"injected line", Edits{Repos: []Repo{{Name: "myrepo", Servers: []string{"https://repo.example.com\nHookDir = /tmp"}}}}, false},
+		{"injected section", Edits{Repos: []Repo{{Name: "myrepo]\n[options"}}}, false},
+		{"include outside pacman.d", Edits{Repos: []Repo{{Name: "myrepo", Include: "/etc/pacman.d/../shadow"}}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.edits.Check(); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// FILE: internal/privileged/allow.go
+package privileged
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// writablePaths are the /etc files the helper may replace or create.
+// pacman.conf is only changed through "pacman-conf", see editPacmanConf.
+var writablePaths = map[string]bool{
+	"/etc/sddm.conf":     true,
+	"/etc/sddm.conf.bkp": true,
+}
+
+const (
+	// sddmThemesDir holds one directory per SDDM theme
+	sddmThemesDir = "/usr/share/sddm/themes"
+	// fontsDir and the directories below it take the themes' fonts
+	fontsDir = "/usr/share/fonts"
+)
+
+// pacmanOps are the pacman operations the helper runs, pacmanFlags the options they may carry.
+var (
+	pacmanOps   = map[string]bool{"-S": true, "-Sy": true, "-Su": true, "-Syu": true, "-Syw": true, "-U": true, "-R": true, "-Rns": true}
+	pacmanFlags = map[string]bool{"--needed": true, "--noconfirm": true, "--asdeps": true}
+)
+
+var (
+	keyIDPattern = regexp.MustCompile(`^(0x)?[0-9A-Fa-f]{8,40}$`)
+	unitPattern  = regexp.MustCompile(`^[A-Za-z0-9@._-]+$`)
+	themePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// caller is the user who started the helper through the escalation backend.
+type caller struct {
+	uid, gid int
+	name     string
+	home     string
+}
+
+// callerFromEnv identifies the caller from what the backend sets: SUDO_USER,
+// SUDO_UID and SUDO_GID for sudo and run0, only DOAS_USER for doas.
+func callerFromEnv() (*caller, error) {
+	name := os.Getenv("SUDO_USER")
+	if name == "" {
+		name = os.Getenv("DOAS_USER")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("the privileged helper must be started through sudo, doas or run0")
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	uid, err1 := strconv.Atoi(u.Uid)
+	gid, err2 := strconv.Atoi(u.Gid)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("unexpected ids for %s", name)
+	}
+	return &caller{uid: uid, gid: gid, name: name, home: u.HomeDir}, nil
+}
+
+// allowedCommand checks a command line against the allow-list. Anything not
+// explicitly known is refused.
+func allowedCommand(command string, args []string, c *caller, ws *workspace) error {
+	var err error
+	switch command {
+	case "pacman":
+		err = allowPacman(args, ws)
+	case "pacman-key":
+		err = allowPacmanKey(args)
+	case "systemctl":
+		err = allowSystemctl(args)
+	case "chsh":
+		err = allowChsh(args, c)
+	case "cp":
+		err = allowCopy(args, c)
+	case "mkdir":
+		err = allowMkdir(args)
+	default:
+		return fmt.Errorf("not allowed: %s", command)
+	}
+	if err != nil {
+		return fmt.Errorf("not allowed: %s %s: %w", command, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// allowPacman accepts install, upgrade, download and removal transactions.
+// A custom --config must be an offline one the helper generated, --dbpath and
+// --cachedir are only for downloading bundles into its temporary directories,
+// and -U only installs package files it staged (see workspace).
+func allowPacman(args []string, ws *workspace) error {
+	var op string
+	var download bool
+	var targets []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--config" || arg == "--dbpath" || arg == "--cachedir":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s needs a value", arg)
+			}
+			i++
+			if !ws.accepts(args[i], strings.TrimPrefix(arg, "--")) {
+				return fmt.Errorf("%s %s was not created by the helper", arg, args[i])
+			}
+			download = download || arg != "--config"
+		case strings.HasPrefix(arg, "-"):
+			if op == "" && pacmanOps[arg] {
+				op = arg
+			} else if !pacmanFlags[arg] {
+				return fmt.Errorf("option %s", arg)
+			}
+		default:
+			targets = append(targets, arg)
+		}
+	}
+	if op == "" {
+		return fmt.Errorf("no operation")
+	}
+	if download && op != "-Syw" {
+		return fmt.Errorf("--dbpath and --cachedir are for downloads only")
+	}
+	for _, t := range targets {
+		// pacman checks the signature of a download against its keyring itself
+		if op == "-U" && !ws.accepts(t, kindPackage) && !strings.HasPrefix(t, "https://") {
+			return fmt.Errorf("%s was not staged by the helper", t)
+		}
+		if op != "-U" && !packageName(t) {
+			return fmt.Errorf("package %s", t)
+		}
+	}
+	return nil
+}
+
+// allowPacmanKey accepts fetching and locally signing repository keys.
+func allowPacmanKey(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no operation")
+	}
+	switch args[0] {
+	case "--recv-keys":
+		if len(args) == 4 && args[2] == "--keyserver" && keyIDPattern.MatchString(args[1]) && strings.HasPrefix(args[3], "hkp") {
+			return nil
+		}
+	case "--lsign-key":
+		if len(args) == 2 && keyIDPattern.MatchString(args[1]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected arguments")
+}
+
+// allowSystemctl accepts enabling or disabling units.
+func allowSystemctl(args []string) error {
+	if len(args) < 2 || (args[0] != "enable" && args[0] != "disable") {
+		return fmt.Errorf("only enable and disable")
+	}
+	for _, arg := range args[1:] {
+		if arg != "--now" && !unitPattern.MatchString(arg) {
+			return fmt.Errorf("unit %s", arg)
+		}
+	}
+	return nil
+}
+
+// allowChsh accepts changing the caller's login shell to one from /etc/shells.
+func allowChsh(args []string, c *caller) error {
+	if len(args) != 3 || args[0] != "-s" {
+		return fmt.Errorf("expected -s SHELL USER")
+	}
+	if args[2] != c.name {
+		return fmt.Errorf("only for %s", c.name)
+	}
+	if !validShell(args[1]) {
+		return fmt.Errorf("%s is not in /etc/shells", args[1])
+	}
+	return nil
+}
+
+func validShell(path string) bool {
+	f, err := os.Open("/etc/shells")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// allowCopy accepts copying the caller's files into an SDDM theme or the
+// fonts (see sharedDestination), and backing up the writable /etc files next
+// to themselves. The copies are root's: -a, which would keep the caller as
+// their owner, is refused.
+func allowCopy(args []string, c *caller) error {
+	var paths []string
+	for _, arg := range args {
+		switch arg {
+		case "-n", "-r", "-f", "-T", "-rf", "-rfT", "-rT":
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("option %s", arg)
+			}
+			paths = append(paths, arg)
+		}
+	}
+	if len(paths) != 2 {
+		return fmt.Errorf("expected SRC DEST")
+	}
+	src, dest := filepath.Clean(paths[0]), filepath.Clean(paths[1])
+
+	if writablePaths[src] && writablePaths[dest] {
+		return nil
+	}
+	if !sharedDestination(dest) {
+		return fmt.Errorf("destination %s", dest)
+	}
+
+	// Resolve links so a symlink in the home can't expose root-only files
+	real, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return err
+	}
+	if !ownedBy(real, c.uid) || !(strings.HasPrefix(real, c.home+"/") || strings.HasPrefix(real, filepath.Clean(os.TempDir())+"/")) {
+		return fmt.Errorf("source must be the caller's own files")
+	}
+	return nil
+}
+
+// allowMkdir accepts creating the directories allowCopy copies into.
+func allowMkdir(args []string) error {
+	if len(args) != 2 || args[0] != "-p" || !sharedDestination(filepath.Clean(args[1])) {
+		return fmt.Errorf("only mkdir -p of an SDDM theme or under %s", fontsDir)
+	}
+	return nil
+}
+
+// sharedDestination reports whether files may be installed into dir (cleaned):
+// an SDDM theme's own directory, or the fonts. Anything else under /usr/share
+// may be read by root (pacman hooks, polkit rules, D-Bus services).
+func sharedDestination(dir string) bool {
+	if filepath.Dir(dir) == sddmThemesDir {
+		return themePattern.MatchString(filepath.Base(dir))
+	}
+	return dir == fontsDir || strings.HasPrefix(dir, fontsDir+"/")
+}
+
+func ownedBy(path string, uid int) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == uid
+}
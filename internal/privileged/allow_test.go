@@ -0,0 +1,63 @@
+// FILE: internal/privileged/allow_test.go
+package privileged
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAllowCopyAndMkdir(t *testing.T) {
+	home := t.TempDir()
+	theme := filepath.Join(home, "Downloads", "SilentSDDM_Setup")
+	if err := os.MkdirAll(filepath.Join(theme, "fonts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c := &caller{uid: os.Getuid(), gid: os.Getgid(), name: "alice", home: home}
+
+	tests := []struct {
+		command string
+		args    []string
+		ok      bool
+	}{
+		{"mkdir", []string{"-p", "/usr/share/sddm/themes/silent"}, true},
+		{"cp", []string{"-rfT", theme, "/usr/share/sddm/themes/silent"}, true},
+		{"mkdir", []string{"-p", "/usr/share/fonts"}, true},
+		{"cp", []string{"-rT", filepath.Join(theme, "fonts"), "/usr/share/fonts"}, true},
+		{"cp", []string{"-rT", filepath.Join(theme, "fonts"), "/usr/share/fonts/silent/"}, true},
+		{"cp", []string{"-n", "/etc/sddm.conf", "/etc/sddm.conf.bkp"}, true},
+
+		// Directories root reads code or rules from
+		{"mkdir", []string{"-p", "/usr/share/libalpm/hooks"}, false},
+		{"cp", []string{"-rT", theme, "/usr/share/libalpm/hooks"}, false},
+		{"cp", []string{"-rT", theme, "/usr/share/polkit-1/rules.d"}, false},
+		{"cp", []string{"-rT", theme, "/usr/share/dbus-1/system-services"}, false},
+		{"cp", []string{"-rT", theme, "/usr/share/sddm/themes"}, false},
+		{"cp", []string{"-rT", theme, "/usr/share/sddm/themes/silent/../../../polkit-1/rules.d"}, false},
+		{"cp", []string{"-rT", theme, "/usr/share/fonts/../libalpm/hooks"}, false},
+		{"cp", []string{"-rT", theme, "/usr/share/fontsx"}, false},
+		{"mkdir", []string{"-p", "/usr/share/sddm/themes/.."}, false},
+		{"mkdir", []string{"/usr/share/fonts"}, false},
+		// -a would leave the caller owning the copies
+		{"cp", []string{"-a", theme, "/usr/share/sddm/themes/silent"}, false},
+		// Not the caller's files
+		{"cp", []string{"-rT", "/etc", "/usr/share/sddm/themes/silent"}, false},
+		// pacman.conf is only edited through pacman-conf
+		{"cp", []string{"-n", "-a", "/etc/pacman.conf", "/etc/pacman.conf.guhwizard.bak"}, false},
+		{"rm", []string{"-f", "/etc/pacman.conf.guhwizard.bak"}, false},
+	}
+	for _, tt := range tests {
+		err := allowedCommand(tt.command, tt.args, c, nil)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s %q: err = %v, want allowed %v", tt.command, tt.args, err, tt.ok)
+		}
+	}
+}
+
+func TestWritablePaths(t *testing.T) {
+	for _, path := range []string{"/etc/pacman.conf", "/etc/pacman.conf.guhwizard.bak", "/etc/sudoers", "/etc/sddm.conf.d/x.conf"} {
+		if writablePaths[path] {
+			t.Errorf("%s is writable through the helper", path)
+		}
+	}
+}
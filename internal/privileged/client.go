@@ -0,0 +1,182 @@
+// FILE: internal/privileged/client.go
+package privileged
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"guhwizard/internal/audit"
+	"guhwizard/internal/escalate"
+	"guhwizard/internal/pacmanconf"
+)
+
+// Client is the installer's end of the pipe to the helper. Requests run one at a time.
+type Client struct {
+	mu sync.Mutex
+	// writeMu lets a cancel go out while a request is waiting for its response
+	writeMu sync.Mutex
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	enc     *json.Encoder
+	dec     *json.Decoder
+}
+
+// Start launches `sudo guhwizard --privileged-helper` (or doas, run0). When
+// interactive, the backend asks for the password on the terminal if needed,
+// so call it before the TUI takes over. Otherwise the credentials must already be cached.
+func Start(interactive bool) (*Client, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := escalate.Current().Command(!interactive, nil, exe, "--"+Flag)
+	if interactive {
+		cmd.Stderr = os.Stderr
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := &Client{cmd: cmd, stdin: stdin, enc: json.NewEncoder(stdin), dec: json.NewDecoder(stdout)}
+	if _, err := c.do(context.Background(), Request{Op: "ping"}, nil); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Exec runs an allowed command as root on a pseudo-terminal and writes its
+// output to term as it appears there, Input answers it. cause is the blueprint
+// entry the audit log attributes it to. Cancelling ctx stops the command.
+func (c *Client) Exec(ctx context.Context, command string, args []string, cause string, term io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err := c.do(ctx, Request{Op: "exec", Command: command, Args: args, Cause: cause}, term)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// Input types p into the terminal of the command Exec is running.
+func (c *Client) Input(p []byte) error {
+	return c.send(Request{Op: "input", Content: p})
+}
+
+// WriteFile atomically replaces one of the allowed /etc files.
+func (c *Client) WriteFile(path string, content []byte, mode os.FileMode, cause string) error {
+	_, err := c.do(context.Background(), Request{Op: "write", Path: path, Content: content, Mode: uint32(mode.Perm()), Cause: cause}, nil)
+	return err
+}
+
+// EditPacmanConf has the helper make edits to /etc/pacman.conf, keeping the
+// original as pacmanconf.BackupPath the first time.
+func (c *Client) EditPacmanConf(edits *pacmanconf.Edits, cause string) error {
+	_, err := c.do(context.Background(), Request{Op: "pacman-conf", Edits: edits, Cause: cause}, nil)
+	return err
+}
+
+// RestorePacmanConf puts the pacman.conf EditPacmanConf backed up back in place.
+func (c *Client) RestorePacmanConf(cause string) error {
+	_, err := c.do(context.Background(), Request{Op: "restore-pacman-conf", Cause: cause}, nil)
+	return err
+}
+
+// Record appends e to the audit log, for privileged work the helper didn't do itself.
+func (c *Client) Record(e audit.Entry) error {
+	_, err := c.do(context.Background(), Request{Op: "record", Entry: &e}, nil)
+	return err
+}
+
+// TempDir creates an empty directory for pacman's --dbpath or --cachedir
+// ("dbpath" or "cachedir"), owned by root and readable by everyone.
+func (c *Client) TempDir(kind string) (string, error) {
+	return c.do(context.Background(), Request{Op: "tempdir", Kind: kind}, nil)
+}
+
+// OfflineConfig has the helper write the pacman.conf for the offline
+// repository repo in dir and returns its path, for --config.
+func (c *Client) OfflineConfig(repo, dir, cause string) (string, error) {
+	return c.do(context.Background(), Request{Op: "offline-config", Kind: repo, Path: dir, Cause: cause}, nil)
+}
+
+// Stage copies a package file into the helper's workspace and returns the
+// copy, the only kind of file pacman -U accepts. It is removed after the install.
+func (c *Client) Stage(path, cause string) (string, error) {
+	return c.do(context.Background(), Request{Op: "stage", Path: path, Cause: cause}, nil)
+}
+
+// Release removes a directory or file TempDir or OfflineConfig created.
+func (c *Client) Release(path string) error {
+	_, err := c.do(context.Background(), Request{Op: "release", Path: path}, nil)
+	return err
+}
+
+// Close ends the helper by closing its stdin and waits for it to exit.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stdin.Close()
+	return c.cmd.Wait()
+}
+
+func (c *Client) do(ctx context.Context, req Request, term io.Writer) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Recorded instead of the helper's own
+	req.Dir, _ = os.Getwd()
+	if err := c.send(req); err != nil {
+		return "", fmt.Errorf("privileged helper is gone: %w", err)
+	}
+	answered := make(chan struct{})
+	defer close(answered)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.send(Request{Op: "cancel"})
+		case <-answered:
+		}
+	}()
+
+	for {
+		var resp Response
+		if err := c.dec.Decode(&resp); err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", fmt.Errorf("privileged helper exited")
+			}
+			return "", err
+		}
+		if resp.Done {
+			if resp.Error != "" {
+				return "", errors.New(resp.Error)
+			}
+			return resp.Path, nil
+		}
+		if term != nil {
+			term.Write(resp.Output)
+		}
+	}
+}
+
+func (c *Client) send(req Request) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.enc.Encode(req)
+}
@@ -0,0 +1,268 @@
+// FILE: internal/privileged/helper.go
+package privileged
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"guhwizard/internal/audit"
+	"guhwizard/internal/pacmanconf"
+	"guhwizard/internal/pty"
+)
+
+// Flag (as --privileged-helper) starts the binary as the helper, see Serve.
+const Flag = "privileged-helper"
+
+// Request is one operation for the helper, sent as a JSON line on its stdin.
+type Request struct {
+	// "ping", "exec", "write", "record", "cancel", "input", "pacman-conf",
+	// "restore-pacman-conf", or for the workspace "tempdir", "offline-config",
+	// "stage" and "release"
+	Op      string   `json:"op"`
+	Cause   string   `json:"cause,omitempty"`
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	Path    string   `json:"path,omitempty"`
+	// Content is the file for "write", what to type into the running command for "input"
+	Content []byte `json:"content,omitempty"`
+	Mode    uint32 `json:"mode,omitempty"`
+	// Kind is the tempdir to create ("dbpath" or "cachedir"), or the repository name for offline-config
+	Kind string `json:"kind,omitempty"`
+	// Dir is the installer's working directory, for the audit log
+	Dir string `json:"dir,omitempty"`
+	// Entry is appended to the audit log by "record", for privileged work done outside the helper
+	Entry *audit.Entry `json:"entry,omitempty"`
+	// Edits are what "pacman-conf" changes in pacman.conf
+	Edits *pacmanconf.Edits `json:"edits,omitempty"`
+}
+
+// Response is output of the running command as it appeared on its terminal,
+// or the final result of a request when Done is set. Path is what a
+// workspace request created.
+type Response struct {
+	Output []byte `json:"output,omitempty"`
+	Done   bool   `json:"done,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+// terminal connects the running command's pseudo-terminal to the installer:
+// its output goes out as responses and "input" requests are typed into it.
+type terminal struct {
+	enc    *json.Encoder
+	mu     sync.Mutex
+	master io.Writer
+}
+
+func (t *terminal) Write(p []byte) (int, error) {
+	return len(p), t.enc.Encode(Response{Output: p})
+}
+
+// attach directs input to master, nil once the command is done.
+func (t *terminal) attach(master io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.master = master
+}
+
+func (t *terminal) input(p []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.master != nil {
+		t.master.Write(p)
+	}
+}
+
+// Serve runs the root side of the helper: it reads requests from in until
+// the installer closes the pipe (or exits), and only executes what allowed accepts.
+// Every exec and write, refused or not, is recorded in the audit log; the
+// helper doesn't start without it. A "cancel" arriving meanwhile stops the
+// request being handled, an "input" answers the command it runs.
+func Serve(in io.Reader, out io.Writer) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("the privileged helper must be started through sudo, doas or run0")
+	}
+	caller, err := callerFromEnv()
+	if err != nil {
+		return err
+	}
+	log, err := audit.Open()
+	if err != nil {
+		return fmt.Errorf("cannot open the audit log: %w", err)
+	}
+	defer log.Close()
+	ws, err := newWorkspace()
+	if err != nil {
+		return fmt.Errorf("cannot create the helper's workspace: %w", err)
+	}
+	defer ws.Close()
+
+	enc := json.NewEncoder(out)
+	term := &terminal{enc: enc}
+	var mu sync.Mutex
+	var cancelRequest context.CancelFunc
+	requests := make(chan Request)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(requests)
+		dec := json.NewDecoder(in)
+		for {
+			var req Request
+			if err := dec.Decode(&req); err != nil {
+				readErr <- err
+				return
+			}
+			switch req.Op {
+			case "cancel":
+				mu.Lock()
+				if cancelRequest != nil {
+					cancelRequest()
+				}
+				mu.Unlock()
+			case "input":
+				term.input(req.Content)
+			default:
+				requests <- req
+			}
+		}
+	}()
+
+	for req := range requests {
+		ctx, cancel := context.WithCancel(context.Background())
+		mu.Lock()
+		cancelRequest = cancel
+		mu.Unlock()
+
+		path, err := handle(ctx, req, caller, ws, term, log)
+
+		mu.Lock()
+		cancelRequest = nil
+		mu.Unlock()
+		cancel()
+
+		resp := Response{Done: true, Path: path}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+
+	if err := <-readErr; !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	return nil
+}
+
+func handle(ctx context.Context, req Request, caller *caller, ws *workspace, term *terminal, log io.Writer) (string, error) {
+	var entry audit.Entry
+	var path string
+	var err error
+	switch req.Op {
+	case "ping":
+		return "", nil
+	case "exec":
+		entry = audit.Begin("exec", append([]string{req.Command}, req.Args...), req.Cause, caller.name, "helper")
+		if err = allowedCommand(req.Command, req.Args, caller, ws); err == nil {
+			err = run(ctx, req.Command, req.Args, term)
+			ws.unstage(req.Args)
+		}
+	case "write":
+		entry = audit.Begin("write", []string{req.Path, fmt.Sprintf("%04o", os.FileMode(req.Mode).Perm())}, req.Cause, caller.name, "helper")
+		if !writablePaths[req.Path] {
+			err = fmt.Errorf("not allowed: writing %s", req.Path)
+		} else {
+			err = writeFile(req.Path, req.Content, os.FileMode(req.Mode).Perm())
+		}
+	case "pacman-conf":
+		entry = audit.Begin("write", []string{pacmanconf.Path}, req.Cause, caller.name, "helper")
+		err = editPacmanConf(req.Edits)
+	case "restore-pacman-conf":
+		entry = audit.Begin("write", []string{pacmanconf.Path, "from", pacmanconf.BackupPath}, req.Cause, caller.name, "helper")
+		err = restorePacmanConf()
+	case "tempdir":
+		path, err = ws.tempDir(req.Kind)
+		return path, err
+	case "offline-config":
+		entry = audit.Begin("write", []string{req.Op, req.Kind, req.Path}, req.Cause, caller.name, "helper")
+		path, err = ws.offlineConfig(req.Kind, req.Path)
+	case "stage":
+		entry = audit.Begin("write", []string{req.Op, req.Path}, req.Cause, caller.name, "helper")
+		path, err = ws.stage(req.Path, caller)
+	case "release":
+		return "", ws.forget(req.Path)
+	case "record":
+		if req.Entry == nil {
+			return "", fmt.Errorf("record without an entry")
+		}
+		// The installer reports it, but it can't claim to be someone else
+		entry = *req.Entry
+		entry.User = caller.name
+		return "", audit.Write(log, entry)
+	default:
+		return "", fmt.Errorf("unknown operation %q", req.Op)
+	}
+
+	entry.Dir = req.Dir
+	entry.Finish(err)
+	if logErr := audit.Write(log, entry); logErr != nil && err == nil {
+		err = fmt.Errorf("audit log: %w", logErr)
+	}
+	return path, err
+}
+
+// run executes an allowed command on a pseudo-terminal and streams its
+// output, until it exits or ctx is cancelled. The installer watches the
+// output and answers through term.
+func run(ctx context.Context, command string, args []string, term *terminal) error {
+	cmd := exec.Command(command, args...)
+	master, err := pty.Start(cmd)
+	if err != nil {
+		return err
+	}
+	term.attach(master)
+	defer term.attach(nil)
+	done := make(chan struct{})
+	defer close(done)
+	go pty.StopOnCancel(ctx, cmd, done)
+
+	err = pty.Wait(cmd, master, term)
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("cancelled: %w", err)
+	}
+	return err
+}
+
+// writeFile replaces path atomically: a root-owned temp file next to it, then a rename.
+func writeFile(path string, content []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".guhwizard-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,67 @@
+// FILE: internal/privileged/pacman_conf.go
+package privileged
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"guhwizard/internal/pacmanconf"
+)
+
+// editPacmanConf applies edits to pacman.conf as it is on disk. The installer
+// only says what to change, Check decides whether that is allowed, so it
+// can't slip in a HookDir, a SigLevel = Never or a server for [core].
+func editPacmanConf(edits *pacmanconf.Edits) error {
+	if edits == nil {
+		return fmt.Errorf("no edits")
+	}
+	if err := edits.Check(); err != nil {
+		return fmt.Errorf("not allowed: %w", err)
+	}
+
+	data, err := os.ReadFile(pacmanconf.Path)
+	if err != nil {
+		return err
+	}
+	f := pacmanconf.Parse(string(data))
+	if err := edits.Apply(f); err != nil {
+		return err
+	}
+	if f.String() == string(data) {
+		return nil
+	}
+
+	// Only the first time, the backup is what the system had before guhwizard
+	if err := createFile(pacmanconf.BackupPath, data, 0644); err != nil && !errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("backing up %s: %w", pacmanconf.Path, err)
+	}
+	return writeFile(pacmanconf.Path, []byte(f.String()), 0644)
+}
+
+// restorePacmanConf puts the backup editPacmanConf made back in place.
+func restorePacmanConf() error {
+	data, err := os.ReadFile(pacmanconf.BackupPath)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(pacmanconf.Path, data, 0644); err != nil {
+		return err
+	}
+	return os.Remove(pacmanconf.BackupPath)
+}
+
+// createFile writes a new file, failing if path exists.
+func createFile(path string, content []byte, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
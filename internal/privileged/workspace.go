@@ -0,0 +1,216 @@
+// FILE: internal/privileged/workspace.go
+package privileged
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+// Kinds of paths the workspace hands out, each accepted only where pacman expects it.
+const (
+	kindConfig   = "config"
+	kindDBPath   = "dbpath"
+	kindCacheDir = "cachedir"
+	kindPackage  = "package"
+	kindStaging  = "staging" // the directory of a staged package
+)
+
+var (
+	// packageFile is "<name>-<pkgver>-<pkgrel>-<arch>.pkg.tar[.<ext>]"
+	packageFile   = regexp.MustCompile(`^([a-z0-9@._+-]+)-([^-/]+-[^-/]+)-[a-z0-9_]+\.pkg\.tar(\.[a-z0-9]+)?$`)
+	packageNameRe = regexp.MustCompile(`^[a-z0-9@._+-]+$`)
+)
+
+// offlineConfig is the pacman.conf for installing from a bundle's repository.
+// The bundled repo is unsigned (repo-add without a key), so it is trusted
+// explicitly. Nothing else is configured, pacman can't reach the network.
+const offlineConfig = `# Generated by guhwizard for an offline install
+[options]
+Architecture = auto
+SigLevel = Required DatabaseOptional
+LocalFileSigLevel = Optional
+
+[%s]
+SigLevel = Optional TrustAll
+Server = file://%s
+`
+
+// workspace is the helper's own temporary directory. The pacman.conf files,
+// databases, caches and package files pacman is pointed at have to come from
+// it: the caller could put a HookDir or XferCommand in a config of theirs, or
+// swap a package file between its check and the install.
+type workspace struct {
+	dir   string
+	paths map[string]string // path -> kind
+}
+
+// newWorkspace creates the directory, root-owned and only traversable by others,
+// so the caller can read a download it knows the name of but not list or change anything.
+func newWorkspace() (*workspace, error) {
+	dir, err := os.MkdirTemp("", "guhwizard-helper-*")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(dir, 0711); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &workspace{dir: dir, paths: make(map[string]string)}, nil
+}
+
+func (w *workspace) Close() error {
+	return os.RemoveAll(w.dir)
+}
+
+// accepts reports whether path is a kind the workspace handed out and still
+// is root-owned and not writable by anyone else.
+func (w *workspace) accepts(path, kind string) bool {
+	clean := filepath.Clean(path)
+	if w.paths[clean] != kind {
+		return false
+	}
+	for _, p := range []string{clean, filepath.Dir(clean), w.dir} {
+		info, err := os.Lstat(p)
+		if err != nil {
+			return false
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok || st.Uid != 0 || info.Mode().Perm()&0022 != 0 || info.Mode()&os.ModeSymlink != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// forget drops path (and anything handed out below it) and removes it.
+func (w *workspace) forget(path string) error {
+	clean := filepath.Clean(path)
+	if _, ok := w.paths[clean]; !ok {
+		return fmt.Errorf("%s was not created by the helper", path)
+	}
+	for p := range w.paths {
+		if p == clean || strings.HasPrefix(p, clean+"/") {
+			delete(w.paths, p)
+		}
+	}
+	return os.RemoveAll(clean)
+}
+
+// tempDir creates a directory for --dbpath (with the local database pacman
+// expects, empty so nothing counts as installed) or --cachedir.
+func (w *workspace) tempDir(kind string) (string, error) {
+	if kind != kindDBPath && kind != kindCacheDir {
+		return "", fmt.Errorf("unknown directory kind %q", kind)
+	}
+	dir, err := os.MkdirTemp(w.dir, kind+"-*")
+	if err != nil {
+		return "", err
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		return "", err
+	}
+	if kind == kindDBPath {
+		if err := os.Mkdir(filepath.Join(dir, "local"), 0755); err != nil {
+			return "", err
+		}
+	}
+	w.paths[dir] = kind
+	return dir, nil
+}
+
+// offlineConfig writes the pacman.conf for the bundle repository in repoDir.
+func (w *workspace) offlineConfig(repoName, repoDir string) (string, error) {
+	if !filepath.IsAbs(repoDir) || strings.ContainsAny(repoDir, "\n\r") || !packageName(repoName) {
+		return "", fmt.Errorf("invalid offline repository %s in %s", repoName, repoDir)
+	}
+	f, err := os.CreateTemp(w.dir, "pacman-*.conf")
+	if err != nil {
+		return "", err
+	}
+	if _, err := fmt.Fprintf(f, offlineConfig, repoName, filepath.Clean(repoDir)); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	w.paths[f.Name()] = kindConfig
+	return f.Name(), nil
+}
+
+// stage copies a package file the caller built (or one root's, like pacman's
+// cache) into the workspace, where it can't change anymore, and checks it is
+// the package its name says. Only staged files are accepted by pacman -U.
+func (w *workspace) stage(path string, c *caller) (string, error) {
+	m := packageFile.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return "", fmt.Errorf("%s is not a package file", path)
+	}
+
+	src, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !info.Mode().IsRegular() || !ok || (int(st.Uid) != c.uid && (st.Uid != 0 || info.Mode().Perm()&0022 != 0)) {
+		return "", fmt.Errorf("%s is not a file owned by %s or root", path, c.name)
+	}
+
+	dir, err := os.MkdirTemp(w.dir, "package-*")
+	if err != nil {
+		return "", err
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		return "", err
+	}
+	w.paths[dir] = kindStaging
+	staged := filepath.Join(dir, filepath.Base(path))
+	dest, err := os.OpenFile(staged, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dest, src); err != nil {
+		dest.Close()
+		return "", err
+	}
+	if err := dest.Close(); err != nil {
+		return "", err
+	}
+	w.paths[staged] = kindPackage
+
+	// The metadata inside has to match the name, pacman -Qp only reads it
+	out, err := exec.Command("pacman", "-Qp", staged).Output()
+	if err != nil || strings.TrimSpace(string(out)) != m[1]+" "+m[2] {
+		w.forget(dir)
+		return "", fmt.Errorf("%s is not a valid package for %s %s", path, m[1], m[2])
+	}
+	return staged, nil
+}
+
+// unstage removes the staged package files in args once pacman -U is done with them.
+func (w *workspace) unstage(args []string) {
+	for _, arg := range args {
+		if w.paths[filepath.Clean(arg)] == kindPackage {
+			w.forget(filepath.Dir(filepath.Clean(arg)))
+		}
+	}
+}
+
+func packageName(name string) bool {
+	return packageNameRe.MatchString(name)
+}
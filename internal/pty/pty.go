@@ -0,0 +1,138 @@
+// FILE: internal/pty/pty.go
+package pty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// The terminal size children see. pacman and curl size their progress bars to it.
+const (
+	rows    = 40
+	columns = 120
+)
+
+// drainTimeout bounds reading after the command exited: a background process
+// it left behind can keep the terminal open indefinitely.
+const drainTimeout = 500 * time.Millisecond
+
+// killGrace is how long a cancelled command gets to clean up (pacman removes
+// its lock) before it is killed.
+const killGrace = 5 * time.Second
+
+// ErrUnavailable wraps failures to allocate a terminal, as opposed to failures to start the command.
+var ErrUnavailable = errors.New("no pseudo-terminal available")
+
+// Open allocates a pseudo-terminal pair from /dev/ptmx.
+func Open() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Fd() would switch master to blocking mode and break read deadlines
+	var n uint32
+	conn, err := master.SyscallConn()
+	if err == nil {
+		ctlErr := conn.Control(func(fd uintptr) {
+			if err = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0); err != nil {
+				return
+			}
+			n, err = unix.IoctlGetUint32(int(fd), unix.TIOCGPTN)
+		})
+		if err == nil {
+			err = ctlErr
+		}
+	}
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pseudo-terminal: %w", err)
+	}
+
+	slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	unix.IoctlSetWinsize(int(slave.Fd()), unix.TIOCSWINSZ, &unix.Winsize{Row: rows, Col: columns})
+	return master, slave, nil
+}
+
+// Start runs cmd in a new session with a pseudo-terminal as its stdin, stdout
+// and stderr, and returns the master side. Reading it yields the combined
+// output, writing it types into the command's stdin.
+func Start(cmd *exec.Cmd) (*os.File, error) {
+	master, slave, err := Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer slave.Close()
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return master, nil
+}
+
+// Wait waits for a command started with Start while copying its output to w.
+// The copy is joined before Wait returns, so no trailing output is lost.
+func Wait(cmd *exec.Cmd, master *os.File, w io.Writer) error {
+	defer master.Close()
+
+	copied := make(chan struct{})
+	go func() {
+		// Ends with EIO once the last process holding the terminal exits
+		io.Copy(w, master)
+		close(copied)
+	}()
+
+	err := cmd.Wait()
+	master.SetReadDeadline(time.Now().Add(drainTimeout))
+	<-copied
+	return err
+}
+
+// StopOnCancel interrupts the process group of cmd when ctx is done before
+// done is closed, like ctrl+c on its terminal. What's left of the group once
+// cmd was waited for, or after killGrace, is killed. cmd must lead its group:
+// Start makes it a session leader.
+func StopOnCancel(ctx context.Context, cmd *exec.Cmd, done <-chan struct{}) {
+	select {
+	case <-ctx.Done():
+	case <-done:
+		return
+	}
+	pgid := cmd.Process.Pid
+	syscall.Kill(-pgid, syscall.SIGINT)
+	select {
+	case <-time.After(killGrace):
+	case <-done:
+	}
+	syscall.Kill(-pgid, syscall.SIGKILL)
+}
+
+// Run is Start followed by Wait.
+func Run(cmd *exec.Cmd, w io.Writer) error {
+	master, err := Start(cmd)
+	if err != nil {
+		return err
+	}
+	return Wait(cmd, master, w)
+}
@@ -0,0 +1,371 @@
+// FILE: internal/pty/screen.go
+package pty
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// liveRows is how many lines at the bottom stay editable: pacman moves the
+// cursor up to redraw one bar per parallel download. Lines scrolling out of it are committed.
+const liveRows = 10
+
+// liveInterval throttles live updates, progress bars redraw far more often than that.
+const liveInterval = 100 * time.Millisecond
+
+// cell is a character with the SGR sequence (colors) it was printed with.
+type cell struct {
+	r     rune
+	style string
+}
+
+// parser states for escape sequences
+const (
+	stateText = iota
+	stateEsc  // after ESC
+	stateCSI  // ESC [ parameters
+	stateOSC  // ESC ] ... until BEL or ESC \
+)
+
+// Screen interprets terminal output into stable lines: carriage returns,
+// backspaces and basic ANSI cursor movement rewrite the lines still on screen,
+// and a line is only committed (OnLine) once the program can no longer change it.
+// The lines still changing are reported through OnLive.
+type Screen struct {
+	OnLine func(line string)
+	OnLive func(lines []string)
+
+	mu       sync.Mutex
+	rows     [][]cell
+	row, col int
+	style    string
+
+	state   int
+	params  []byte
+	partial []byte // incomplete UTF-8 sequence from the previous write
+
+	lastLive  time.Time
+	timer     *time.Timer
+	dirty     bool
+	lastWrite time.Time
+}
+
+// NewScreen returns a Screen reporting to onLine and onLive (which may be nil).
+func NewScreen(onLine func(string), onLive func([]string)) *Screen {
+	return &Screen{OnLine: onLine, OnLive: onLive, rows: [][]cell{nil}, lastWrite: time.Now()}
+}
+
+// Write feeds output to the screen. It never fails.
+func (s *Screen) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := p
+	if len(s.partial) > 0 {
+		data = append(s.partial, p...)
+		s.partial = nil
+	}
+	for len(data) > 0 {
+		if s.state == stateText && data[0] >= utf8.RuneSelf {
+			if !utf8.FullRune(data) {
+				s.partial = append([]byte(nil), data...)
+				break
+			}
+			r, size := utf8.DecodeRune(data)
+			s.put(r)
+			data = data[size:]
+			continue
+		}
+		s.feed(data[0])
+		data = data[1:]
+	}
+
+	s.dirty = true
+	s.lastWrite = time.Now()
+	s.scheduleLive()
+	return len(p), nil
+}
+
+// Flush commits every remaining line, at the end of the output.
+func (s *Screen) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	last := len(s.rows)
+	if len(s.rows[last-1]) == 0 {
+		last--
+	}
+	s.commit(last)
+	s.rows, s.row, s.col = [][]cell{nil}, 0, 0
+	if s.OnLive != nil {
+		s.OnLive(nil)
+	}
+}
+
+// Text returns the lines that are still changing without colors, the last one
+// being where the cursor is when a program waits for input.
+func (s *Screen) Text() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var lines []string
+	for i, line := range s.rows {
+		if i == len(s.rows)-1 && len(line) == 0 {
+			break
+		}
+		text := make([]rune, len(line))
+		for j, c := range line {
+			text[j] = c.r
+		}
+		lines = append(lines, strings.TrimRight(string(text), " "))
+	}
+	return lines
+}
+
+// LastOutput is when the program last printed something (or the screen was created).
+func (s *Screen) LastOutput() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastWrite
+}
+
+func (s *Screen) feed(b byte) {
+	switch s.state {
+	case stateEsc:
+		switch b {
+		case '[':
+			s.state, s.params = stateCSI, s.params[:0]
+		case ']':
+			s.state = stateOSC
+		default:
+			// Two-byte sequences (charset selection, keypad modes) don't affect the text
+			s.state = stateText
+		}
+		return
+	case stateCSI:
+		if b >= 0x40 && b <= 0x7e {
+			s.csi(b)
+			s.state = stateText
+		} else {
+			s.params = append(s.params, b)
+		}
+		return
+	case stateOSC:
+		// Window titles and the like, terminated by BEL or ST (ESC \)
+		if b == '\a' {
+			s.state = stateText
+		} else if b == 0x1b {
+			s.state = stateEsc
+		}
+		return
+	}
+
+	switch b {
+	case 0x1b:
+		s.state = stateEsc
+	case '\r':
+		s.col = 0
+	case '\n':
+		s.newline()
+	case '\b':
+		if s.col > 0 {
+			s.col--
+		}
+	case '\t':
+		s.col = (s.col/8 + 1) * 8
+	default:
+		if b >= 0x20 && b != 0x7f {
+			s.put(rune(b))
+		}
+	}
+}
+
+// csi applies ESC [ params final. Anything beyond cursor movement, erasing
+// and colors (scroll regions, absolute positioning) is ignored.
+func (s *Screen) csi(final byte) {
+	params := string(s.params)
+	if strings.HasPrefix(params, "?") {
+		// Private modes, e.g. hiding the cursor
+		return
+	}
+	n := 1
+	if v, err := strconv.Atoi(strings.SplitN(params, ";", 2)[0]); err == nil && v > 0 {
+		n = v
+	}
+
+	switch final {
+	case 'A':
+		s.row -= n
+		if s.row < 0 {
+			s.row = 0
+		}
+	case 'B':
+		for i := 0; i < n; i++ {
+			s.down()
+		}
+	case 'C':
+		s.col += n
+	case 'D':
+		s.col -= n
+		if s.col < 0 {
+			s.col = 0
+		}
+	case 'G':
+		s.col = n - 1
+	case 'K':
+		line := s.rows[s.row]
+		switch params {
+		case "", "0":
+			if s.col < len(line) {
+				s.rows[s.row] = line[:s.col]
+			}
+		case "1":
+			for i := 0; i < s.col && i < len(line); i++ {
+				line[i] = cell{r: ' '}
+			}
+		case "2":
+			s.rows[s.row] = nil
+		}
+	case 'J':
+		if params == "" || params == "0" {
+			s.rows[s.row] = truncate(s.rows[s.row], s.col)
+			s.rows = s.rows[:s.row+1]
+		}
+	case 'm':
+		if params == "" || params == "0" {
+			s.style = ""
+		} else {
+			s.style += "\x1b[" + params + "m"
+		}
+	}
+}
+
+func truncate(line []cell, n int) []cell {
+	if n < len(line) {
+		return line[:n]
+	}
+	return line
+}
+
+// put prints r at the cursor, overwriting what is there.
+func (s *Screen) put(r rune) {
+	line := s.rows[s.row]
+	for len(line) < s.col {
+		line = append(line, cell{r: ' '})
+	}
+	c := cell{r: r, style: s.style}
+	if s.col < len(line) {
+		line[s.col] = c
+	} else {
+		line = append(line, c)
+	}
+	s.rows[s.row] = line
+	s.col++
+}
+
+func (s *Screen) newline() {
+	s.col = 0
+	s.down()
+}
+
+// down moves the cursor one line down, adding a line at the bottom, and
+// commits the lines the program can't reach anymore.
+func (s *Screen) down() {
+	s.row++
+	if s.row == len(s.rows) {
+		s.rows = append(s.rows, nil)
+	}
+	if len(s.rows) > liveRows {
+		s.commit(len(s.rows) - liveRows)
+	}
+}
+
+// commit reports the first n lines and drops them from the screen.
+func (s *Screen) commit(n int) {
+	if n <= 0 {
+		return
+	}
+	if s.OnLine != nil {
+		for _, line := range s.rows[:n] {
+			s.OnLine(render(line))
+		}
+	}
+	s.rows = append([][]cell(nil), s.rows[n:]...)
+	s.row -= n
+	if s.row < 0 {
+		s.row = 0
+	}
+	if len(s.rows) == 0 {
+		s.rows = [][]cell{nil}
+	}
+}
+
+func (s *Screen) live() []string {
+	var lines []string
+	for i, line := range s.rows {
+		if i == len(s.rows)-1 && len(line) == 0 {
+			break
+		}
+		lines = append(lines, render(line))
+	}
+	return lines
+}
+
+// scheduleLive reports the live lines now, or once liveInterval has passed since the last report.
+func (s *Screen) scheduleLive() {
+	if s.OnLive == nil || s.timer != nil {
+		return
+	}
+	wait := liveInterval - time.Since(s.lastLive)
+	if wait <= 0 {
+		s.reportLive()
+		return
+	}
+	s.timer = time.AfterFunc(wait, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.timer != nil {
+			s.timer = nil
+			s.reportLive()
+		}
+	})
+}
+
+func (s *Screen) reportLive() {
+	if !s.dirty {
+		return
+	}
+	s.dirty = false
+	s.lastLive = time.Now()
+	s.OnLive(s.live())
+}
+
+// render turns cells back into text, with the color sequences they were printed with.
+func render(line []cell) string {
+	var b strings.Builder
+	style := ""
+	end := len(line)
+	for end > 0 && line[end-1].r == ' ' && line[end-1].style == "" {
+		end--
+	}
+	for _, c := range line[:end] {
+		if c.style != style {
+			if style != "" {
+				b.WriteString("\x1b[0m")
+			}
+			b.WriteString(c.style)
+			style = c.style
+		}
+		b.WriteRune(c.r)
+	}
+	if style != "" {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
@@ -0,0 +1,86 @@
+// FILE: internal/pty/screen_test.go
+package pty
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestScreen(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		lines  []string
+	}{
+		{
+			name:   "carriage return progress bar",
+			writes: []string{"core  10%\r", "core  55%\r", "core 100%\n", "done\n"},
+			lines:  []string{"core 100%", "done"},
+		},
+		{
+			name:   "erasing the rest of a shorter redraw",
+			writes: []string{"downloading...\rok\x1b[K\n"},
+			lines:  []string{"ok"},
+		},
+		{
+			name: "cursor up redraws parallel downloads",
+			writes: []string{
+				"a   0%\nb   0%\n",
+				"\x1b[2A\x1b[2Ka 100%\n\x1b[2Kb  40%\n",
+				"\x1b[1A\x1b[2Kb 100%\n",
+			},
+			lines: []string{"a 100%", "b 100%"},
+		},
+		{
+			name:   "UTF-8 split across writes",
+			writes: []string{"caf\xc3", "\xa9 \xe2\x94", "\x80\n"},
+			lines:  []string{"café ─"},
+		},
+		{
+			name:   "colors are kept, trailing spaces dropped",
+			writes: []string{"\x1b[1;32mok\x1b[0m   \n"},
+			lines:  []string{"\x1b[1;32mok\x1b[0m"},
+		},
+		{
+			name:   "backspace and window title",
+			writes: []string{"\x1b]0;title\aabc\b\bX\n"},
+			lines:  []string{"aXc"},
+		},
+		{
+			name:   "unterminated last line",
+			writes: []string{"one\ntwo"},
+			lines:  []string{"one", "two"},
+		},
+	}
+	for _, tt := range tests {
+		var lines []string
+		s := NewScreen(func(line string) { lines = append(lines, line) }, nil)
+		for _, w := range tt.writes {
+			s.Write([]byte(w))
+		}
+		s.Flush()
+		if !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf("%s: lines = %q, want %q", tt.name, lines, tt.lines)
+		}
+	}
+}
+
+func TestScreenCommitsScrolledLines(t *testing.T) {
+	var lines []string
+	s := NewScreen(func(line string) { lines = append(lines, line) }, nil)
+	for i := 1; i <= liveRows+2; i++ {
+		s.Write([]byte(strconv.Itoa(i) + "\n"))
+	}
+	// Only the lines that left the live rows are final
+	if want := []string{"1", "2", "3"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("committed %q before Flush, want %q", lines, want)
+	}
+
+	// A prompt waits on the last line, where the cursor is
+	s.Write([]byte(":: Proceed with installation? [Y/n] "))
+	text := s.Text()
+	if got := text[len(text)-1]; got != ":: Proceed with installation? [Y/n]" {
+		t.Errorf("Text() ends with %q, want the prompt", got)
+	}
+}
@@ -5,39 +5,137 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 )
 
 const SudoersFile = "/etc/sudoers.d/99-no-password-until-reboot"
-const SudoersContent = "Defaults timestamp_timeout=-1\n"
 
-// ConfigureSudoTimestamp creates a temporary sudoers file to allow passwordless sudo until reboot.
-// It validates the file using visudo before claiming success.
+// LegacySudoersContent is the system-wide rule older versions wrote.
+const LegacySudoersContent = "Defaults timestamp_timeout=-1\n"
+
+// sudoersHeader marks the rule as guhwizard's own.
+const sudoersHeader = "# Managed by guhwizard, removed by 'guhwizard --root-teardown'\n"
+
+// RuleLifetime is how long a rule may stay before the next launch removes it.
+const RuleLifetime = 6 * time.Hour
+
+// userPattern keeps the user name safe to embed in sudoers syntax.
+var userPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]*\$?$`)
+
+// ConfigureSudoTimestamp creates a sudoers rule that keeps the invoking user's sudo
+// credentials cached for the rest of the session (Defaults:USER timestamp_timeout=-1).
+// The rule is validated with visudo before it is put in place, and recorded in
+// StateFile so the end of the run, --root-teardown or the expiry can remove it.
 func ConfigureSudoTimestamp() error {
 	if os.Geteuid() != 0 {
 		return fmt.Errorf("this mode must be run as root")
 	}
 
-	fmt.Println("Configuring passwordless sudo until reboot...")
+	user := os.Getenv("SUDO_USER")
+	if user == "" || user == "root" {
+		return fmt.Errorf("run this through sudo as the user who will install, the rule is scoped to them")
+	}
+	if !userPattern.MatchString(user) {
+		return fmt.Errorf("unsupported user name %q", user)
+	}
+
+	fmt.Printf("Keeping sudo credentials cached for %s (expires in %s)...\n", user, RuleLifetime)
 
-	// 1. Write the content
-	if err := os.WriteFile(SudoersFile, []byte(SudoersContent), 0440); err != nil {
+	content := ruleContent(user)
+
+	// 1. Stage it under a name sudo ignores (it skips files containing a dot)
+	staged := SudoersFile + ".guhwizard-new"
+	if err := os.WriteFile(staged, []byte(content), 0440); err != nil {
 		return fmt.Errorf("failed to write sudoers file: %w", err)
 	}
+	defer os.Remove(staged)
 
 	// 2. Set permissions (must be 0440)
-	if err := os.Chmod(SudoersFile, 0440); err != nil {
-		os.Remove(SudoersFile) // Cleanup
+	if err := os.Chmod(staged, 0440); err != nil {
 		return fmt.Errorf("failed to set permissions: %w", err)
 	}
 
-	// 3. Validate with visudo
+	// 3. Validate with visudo before it can take effect
 	// -c: check-only, -f: file path
-	cmd := exec.Command("visudo", "-cf", SudoersFile)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		os.Remove(SudoersFile) // CRITICAL: Cleanup invalid file
+	if out, err := exec.Command("visudo", "-cf", staged).CombinedOutput(); err != nil {
 		return fmt.Errorf("visudo validation failed: %s", string(out))
 	}
 
-	fmt.Println("Success: Sudo timestamp timeout set to -1.")
+	// 4. Record it first, so a rule in place is always one guhwizard knows about
+	rule := &Rule{User: user, Created: time.Now(), Expires: time.Now().Add(RuleLifetime), Digest: digest([]byte(content))}
+	if err := writeState(rule); err != nil {
+		return fmt.Errorf("failed to record the sudoers rule: %w", err)
+	}
+
+	if err := os.Rename(staged, SudoersFile); err != nil {
+		os.Remove(StateFile)
+		return fmt.Errorf("failed to install sudoers file: %w", err)
+	}
+
+	fmt.Printf("Success: sudo credentials stay cached for %s until %s.\n", user, rule.Expires.Format(time.Kitchen))
 	return nil
 }
+
+// ruleContent is the sudoers file ConfigureSudoTimestamp writes for user.
+func ruleContent(user string) string {
+	return sudoersHeader + fmt.Sprintf("Defaults:%s timestamp_timeout=-1\n", user)
+}
+
+// TeardownSudoers removes the rule ConfigureSudoTimestamp created, and only that:
+// the file has to be exactly what the state file recorded (or, without one, a
+// rule guhwizard writes). Anything else, like a rule edited since, is left alone.
+func TeardownSudoers() error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("this mode must be run as root")
+	}
+
+	data, err := os.ReadFile(SudoersFile)
+	if os.IsNotExist(err) {
+		os.Remove(StateFile)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rule, err := CurrentRule()
+	if err != nil {
+		return err
+	}
+	if !isOurs(data, rule) {
+		return fmt.Errorf("%s is not the rule guhwizard created, not removing it", SudoersFile)
+	}
+
+	if err := os.Remove(SudoersFile); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", SudoersFile, err)
+	}
+	os.Remove(StateFile)
+
+	// The remaining configuration must still parse, or sudo stops working
+	if out, err := exec.Command("visudo", "-c").CombinedOutput(); err != nil {
+		return fmt.Errorf("sudoers configuration is invalid after removing the rule: %s", string(out))
+	}
+
+	fmt.Printf("Removed %s.\n", filepath.Base(SudoersFile))
+	return nil
+}
+
+// isOurs compares the sudoers file with the rule recorded for it.
+func isOurs(data []byte, rule *Rule) bool {
+	switch {
+	case rule != nil && rule.Digest != "":
+		return digest(data) == rule.Digest
+	case rule != nil:
+		return string(data) == ruleContent(rule.User)
+	}
+	// Left by a version that didn't keep state
+	if string(data) == LegacySudoersContent {
+		return true
+	}
+	user, ok := strings.CutPrefix(string(data), sudoersHeader+"Defaults:")
+	user, ok2 := strings.CutSuffix(user, " timestamp_timeout=-1\n")
+	return ok && ok2 && userPattern.MatchString(user)
+}
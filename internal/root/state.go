@@ -0,0 +1,84 @@
+// FILE: internal/root/state.go
+package root
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// StateFile records the sudoers rule guhwizard created. Unlike the rule itself
+// (sudoers.d is root-only) it is world-readable, so the unprivileged installer
+// can tell whether there is a rule to remove.
+const StateFile = "/var/lib/guhwizard/sudoers.state"
+
+// Rule describes the sudoers rule currently in place.
+type Rule struct {
+	User    string
+	Created time.Time
+	Expires time.Time
+	// Digest is the SHA-256 of the file guhwizard wrote, empty in older state files
+	Digest string
+}
+
+// Expired reports whether the rule outlived RuleLifetime.
+func (r *Rule) Expired() bool {
+	return time.Now().After(r.Expires)
+}
+
+// CurrentRule returns the recorded rule, or nil if guhwizard has none in place.
+func CurrentRule() (*Rule, error) {
+	f, err := os.Open(StateFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rule := &Rule{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "user":
+			rule.User = value
+		case "created":
+			rule.Created, _ = time.Parse(time.RFC3339, value)
+		case "expires":
+			rule.Expires, _ = time.Parse(time.RFC3339, value)
+		case "sha256":
+			rule.Digest = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if rule.User == "" || rule.Expires.IsZero() {
+		return nil, fmt.Errorf("%s is corrupt", StateFile)
+	}
+	return rule, nil
+}
+
+func writeState(rule *Rule) error {
+	if err := os.MkdirAll(filepath.Dir(StateFile), 0755); err != nil {
+		return err
+	}
+	content := fmt.Sprintf("user=%s\ncreated=%s\nexpires=%s\nsha256=%s\n",
+		rule.User, rule.Created.Format(time.RFC3339), rule.Expires.Format(time.RFC3339), rule.Digest)
+	return os.WriteFile(StateFile, []byte(content), 0644)
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -22,6 +22,9 @@ var (
     Highlight = lipgloss.NewStyle().Foreground(lipgloss.Color(ColorMauve)).Bold(true)
     Error     = lipgloss.NewStyle().Foreground(lipgloss.Color(ColorRed))
     Success   = lipgloss.NewStyle().Foreground(lipgloss.Color(ColorGreen))
+
+    // Badge shown next to items that are already installed
+    InstalledBadge = lipgloss.NewStyle().Foreground(lipgloss.Color(ColorGreen)).Italic(true)
     
     // Logo Style
     LogoStyle = lipgloss.NewStyle().
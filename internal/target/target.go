@@ -0,0 +1,237 @@
+// FILE: internal/target/target.go
+package target
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// User is the account the install is for: it gets the dotfiles, the login
+// shell and the terminal config, and it builds AUR packages (makepkg refuses root).
+type User struct {
+	Name string
+	UID  int
+	GID  int
+	Home string
+	// groups are the supplementary groups its commands run with
+	groups []uint32
+}
+
+// current is set by Init. Until then Current is the user running guhwizard.
+var current *User
+
+// Init determines the target user: name when given (--target-user), which
+// takes root to set up. Otherwise it's the user running guhwizard, or as root
+// the one who started it through sudo, doas or run0 (SUDO_USER, DOAS_USER),
+// and without one there is nobody to install for.
+func Init(name string) error {
+	if name == "" {
+		u, err := resolve()
+		if err != nil {
+			return err
+		}
+		current = u
+		return nil
+	}
+
+	u, err := Check(name)
+	if err != nil {
+		return err
+	}
+	if os.Geteuid() != 0 && u.UID != os.Getuid() {
+		return fmt.Errorf("installing for %s needs root to write their home: run guhwizard through sudo", name)
+	}
+	current = u
+	return nil
+}
+
+// inPasswd reports whether name is a local account. Directory services
+// (LDAP, systemd-homed) could resolve it too, but their homes may not be there yet.
+func inPasswd(name string) bool {
+	f, err := os.Open("/etc/passwd")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if user, _, ok := strings.Cut(scanner.Text(), ":"); ok && user == name {
+			return true
+		}
+	}
+	return false
+}
+
+func resolve() (*User, error) {
+	if os.Geteuid() != 0 {
+		u, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		return fromUser(u)
+	}
+
+	name := os.Getenv("SUDO_USER")
+	if name == "" {
+		name = os.Getenv("DOAS_USER")
+	}
+	if name == "" || name == "root" {
+		return nil, fmt.Errorf("guhwizard is running as root and can't tell which user to install for: " +
+			"run it as that user (it asks for root itself), or through sudo from their account")
+	}
+	return Lookup(name)
+}
+
+// Check returns the user name, if guhwizard can install for them: a local
+// account other than root, with a home of their own. Everything guhwizard
+// writes there runs as them (see fs.TargetWriteFile), so it can't reach
+// further than they can.
+func Check(name string) (*User, error) {
+	if !inPasswd(name) {
+		return nil, fmt.Errorf("no user %s in /etc/passwd", name)
+	}
+	u, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if u.UID == 0 {
+		return nil, fmt.Errorf("can't install for root: makepkg refuses to build as root")
+	}
+	info, err := os.Lstat(u.Home)
+	if err != nil {
+		return nil, fmt.Errorf("%s has no home directory: %w", name, err)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); !info.IsDir() || !ok || int(st.Uid) != u.UID {
+		return nil, fmt.Errorf("%s is not a directory owned by %s", u.Home, name)
+	}
+	return u, nil
+}
+
+// Lookup returns the account called name.
+func Lookup(name string) (*User, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return fromUser(u)
+}
+
+func fromUser(u *user.User) (*User, error) {
+	uid, err1 := strconv.Atoi(u.Uid)
+	gid, err2 := strconv.Atoi(u.Gid)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("unexpected ids for %s", u.Username)
+	}
+	t := &User{Name: u.Username, UID: uid, GID: gid, Home: u.HomeDir}
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if g, err := strconv.Atoi(id); err == nil {
+				t.groups = append(t.groups, uint32(g))
+			}
+		}
+	}
+	return t, nil
+}
+
+// Current returns the target user, the user running guhwizard if Init wasn't called.
+func Current() *User {
+	if current == nil {
+		u, err := resolve()
+		if err != nil {
+			// Root without a target: HOME and friends are root's
+			home, _ := os.UserHomeDir()
+			return &User{Name: "root", Home: home}
+		}
+		current = u
+	}
+	return current
+}
+
+// Switching reports whether commands for the target user need to drop to
+// them: guhwizard runs as root and installs for someone else.
+func Switching() bool {
+	return Current().switching()
+}
+
+// switching reports whether commands for u need to drop to it: guhwizard runs as root and u isn't.
+func (u *User) switching() bool {
+	return os.Geteuid() == 0 && u.UID != 0
+}
+
+// CacheDir is u's cache directory (XDG_CACHE_HOME only applies to the user running guhwizard).
+func (u *User) CacheDir() (string, error) {
+	if !u.switching() && u.UID == os.Getuid() {
+		return os.UserCacheDir()
+	}
+	return filepath.Join(u.Home, ".cache"), nil
+}
+
+// RuntimeDir is u's XDG_RUNTIME_DIR, empty when they have no session (or systemd user manager).
+func (u *User) RuntimeDir() string {
+	if !u.switching() && u.UID == os.Getuid() {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			return dir
+		}
+	}
+	dir := fmt.Sprintf("/run/user/%d", u.UID)
+	if _, err := os.Stat(dir); err != nil {
+		return ""
+	}
+	return dir
+}
+
+// Command builds a command that runs as the target user, see Adopt.
+func Command(name string, args ...string) *exec.Cmd {
+	return Adopt(exec.Command(name, args...))
+}
+
+// Adopt makes cmd run as the target user. As root it drops to their uid, gid
+// and groups, with HOME, USER, LOGNAME and XDG_RUNTIME_DIR (when they have a
+// session) pointing at them. Otherwise it already runs as them and is left alone.
+func Adopt(cmd *exec.Cmd) *exec.Cmd {
+	u := Current()
+	if !u.switching() {
+		return cmd
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(u.UID), Gid: uint32(u.GID), Groups: u.groups}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(withoutVars(env, "HOME", "USER", "LOGNAME", "XDG_CACHE_HOME", "XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_RUNTIME_DIR", "DBUS_SESSION_BUS_ADDRESS"),
+		"HOME="+u.Home, "USER="+u.Name, "LOGNAME="+u.Name)
+	if dir := u.RuntimeDir(); dir != "" {
+		cmd.Env = append(cmd.Env, "XDG_RUNTIME_DIR="+dir)
+	}
+	return cmd
+}
+
+func withoutVars(env []string, names ...string) []string {
+	var out []string
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		drop := false
+		for _, n := range names {
+			if name == n {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
@@ -4,9 +4,13 @@ package ui
 import (
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"guhwizard/internal/config"
+	"guhwizard/internal/control"
 	"guhwizard/internal/engine"
+	"guhwizard/internal/escalate"
+	"guhwizard/internal/installer"
 	"guhwizard/internal/styles"
 
 	"github.com/charmbracelet/bubbles/list"
@@ -21,6 +25,8 @@ const (
 	StateWelcome AppState = iota
 	StateSelection
 	StateConfirmation
+	StateConflicts
+	StatePassword
 	StateInstalling
 	StateDone
 )
@@ -28,6 +34,48 @@ const (
 type installMsg struct{ err error }
 type logMsg string
 
+// liveMsg carries the lines a running command is still redrawing (progress bars).
+type liveMsg []string
+
+// pacmanConfMsg carries the pacman.conf diff shown before confirming.
+type pacmanConfMsg struct {
+	diff string
+	err  error
+}
+
+// upgradeMsg carries the pending system upgrade shown before confirming.
+type upgradeMsg struct {
+	summary *installer.UpgradeSummary
+	err     error
+}
+
+// conflictMsg carries the conflicts between selected and installed packages.
+type conflictMsg struct {
+	conflicts []installer.Conflict
+	err       error
+}
+
+// sudoMsg reports whether sudo needs a password before installing.
+type sudoMsg struct{ needed bool }
+
+// authMsg is the result of authenticating with the entered password.
+type authMsg struct {
+	err       error
+	helperErr error
+}
+
+// installedMsg carries the local package database, queried at startup.
+type installedMsg struct {
+	versions map[string]string
+	err      error
+}
+
+// packageCheckMsg carries the result of resolving the selected package names.
+type packageCheckMsg struct {
+	missing []string
+	err     error
+}
+
 type Model struct {
 	state          AppState
 	cfg            *config.Config
@@ -42,11 +90,40 @@ type Model struct {
 	viewport viewport.Model
 
 	// Data & Channels
-	logChannel  chan string
-	progChannel chan engine.ProgressMsg
-	logs        []string
-	showLogs    bool
-	statusMsg   string
+	logChannel    chan string
+	progChannel   chan engine.ProgressMsg
+	promptChannel chan engine.PromptMsg
+	liveChannel   chan []string
+	logs          []string
+	liveLines     []string
+	showLogs      bool
+	statusMsg     string
+
+	// Package name validation, run when entering StateConfirmation
+	checkingPackages bool
+	missingPackages  []string
+	packageCheckErr  error
+	pacmanConfDiff   string
+	pacmanConfErr    error
+	pendingUpgrade   *installer.UpgradeSummary
+	upgradeErr       error
+
+	// Conflicts with installed packages, resolved in StateConflicts
+	checkingConflicts bool
+	conflicts         []installer.Conflict
+	conflictErr       error
+	conflictCursor    int
+
+	// sudo password, asked for when there are no cached credentials.
+	// password is wiped as soon as it is handed to sudo.
+	needPassword   bool
+	password       []byte
+	authenticating bool
+	authErr        error
+
+	// Questions from the installer, answered in a dialog while installing
+	prompts      []control.Prompt
+	promptCursor int
 }
 
 func NewModel(cfg *config.Config) Model {
@@ -59,7 +136,9 @@ func NewModel(cfg *config.Config) Model {
 	// 3. Setup Channels & Engine
 	logChan := make(chan string, 100)
 	progChan := make(chan engine.ProgressMsg, 100)
-	runner := engine.NewRunner(cfg, logChan, progChan)
+	promptChan := make(chan engine.PromptMsg, 10)
+	liveChan := make(chan []string, 1)
+	runner := engine.NewRunner(cfg, logChan, progChan, promptChan, liveChan)
 
 	// 4. Setup List with CUSTOM DELEGATE
 	l := list.New([]list.Item{}, CustomDelegate{}, 0, 0)
@@ -67,14 +146,16 @@ func NewModel(cfg *config.Config) Model {
 	l.Styles.Title = styles.Highlight
 
 	m := Model{
-		state:       StateWelcome,
-		cfg:         cfg,
-		runner:      runner,
-		progress:    prog,
-		viewport:    vp,
-		list:        l,
-		logChannel:  logChan,
-		progChannel: progChan,
+		state:         StateWelcome,
+		cfg:           cfg,
+		runner:        runner,
+		progress:      prog,
+		viewport:      vp,
+		list:          l,
+		logChannel:    logChan,
+		progChannel:   progChan,
+		promptChannel: promptChan,
+		liveChannel:   liveChan,
 	}
 
 	return m
@@ -88,14 +169,123 @@ func waitForLog(sub chan string) tea.Cmd {
 	}
 }
 
+func waitForLive(sub chan []string) tea.Cmd {
+	return func() tea.Msg {
+		return liveMsg(<-sub)
+	}
+}
+
 func waitForProgress(sub chan engine.ProgressMsg) tea.Cmd {
 	return func() tea.Msg {
 		return <-sub
 	}
 }
 
+func waitForPrompt(sub chan engine.PromptMsg) tea.Cmd {
+	return func() tea.Msg {
+		return <-sub
+	}
+}
+
+// checkPackages resolves every package the install would touch against the repos and the AUR.
+func checkPackages(cfg *config.Config) tea.Cmd {
+	pkgs := installer.SelectedPackages(cfg)
+	return func() tea.Msg {
+		idx, err := installer.OnlineIndex()
+		if err != nil {
+			return packageCheckMsg{err: err}
+		}
+		missing, err := idx.Unresolved(pkgs)
+		return packageCheckMsg{missing: missing, err: err}
+	}
+}
+
+// queryInstalled reads the local package database so the lists can show what is already there.
+func queryInstalled() tea.Msg {
+	versions, err := installer.LocalPackages()
+	return installedMsg{versions: versions, err: err}
+}
+
+// planPacmanConf previews the pacman.conf edits of the pacman step.
+func planPacmanConf(cfg *config.Config) tea.Cmd {
+	return func() tea.Msg {
+		diff, err := installer.PacmanConfDiff(cfg)
+		return pacmanConfMsg{diff: diff, err: err}
+	}
+}
+
+// checkConflicts looks for selected packages that conflict with installed ones.
+func checkConflicts(cfg *config.Config) tea.Cmd {
+	return func() tea.Msg {
+		aur, err := installer.NewAURBackend(cfg)
+		if err != nil {
+			return conflictMsg{err: err}
+		}
+		conflicts, err := installer.DetectConflicts(cfg, &installer.Pacman{}, aur)
+		return conflictMsg{conflicts: conflicts, err: err}
+	}
+}
+
+// checkUpgrade sizes the system upgrade that runs before the packages.
+func checkUpgrade() tea.Msg {
+	summary, err := installer.PendingUpgrade()
+	return upgradeMsg{summary: summary, err: err}
+}
+
+// checkSudo finds out whether the install will need the sudo password.
+func checkSudo() tea.Msg {
+	// Only sudo takes the password from us, the other backends asked before the TUI started
+	needed := !installer.HelperRunning() && escalate.Current().AcceptsPassword() && installer.ValidateSudo() != nil
+	return sudoMsg{needed: needed}
+}
+
+// authenticate feeds the password to sudo (which wipes it), then starts the
+// privileged helper on the cached credentials.
+func authenticate(password []byte) tea.Cmd {
+	return func() tea.Msg {
+		if err := installer.CurrentSession.StartSudoKeepAlive(password); err != nil {
+			return authMsg{err: err}
+		}
+		return authMsg{helperErr: installer.StartPrivilegedHelper(false)}
+	}
+}
+
 func (m Model) Init() tea.Cmd {
-	return nil
+	return tea.Batch(queryInstalled, checkSudo)
+}
+
+// markInstalled flags installed items and, if the blueprint asks for it, pre-checks them.
+// Pre-checking only happens before the user has started making choices.
+func (m *Model) markInstalled(versions map[string]string) {
+	preselect := m.cfg.Settings.PreselectInstalled && m.state == StateWelcome
+
+	for s := range m.cfg.Steps {
+		step := &m.cfg.Steps[s]
+		for i := range step.Items {
+			item := &step.Items[i]
+			version, ok := versions[item.Name]
+			item.Installed = ok
+			item.InstalledVersion = version
+
+			if !ok || !preselect {
+				continue
+			}
+			// Single-select steps keep at most one pre-checked item
+			if step.Type == "single" && hasSelection(step) {
+				continue
+			}
+			item.Selected = true
+		}
+	}
+}
+
+func hasSelection(step *config.Step) bool {
+	for _, item := range step.Items {
+		if item.Selected {
+			return true
+		}
+	}
+	return false
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -117,10 +307,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// --- Channel Handling ---
 	case logMsg:
 		m.logs = append(m.logs, string(msg))
-		m.viewport.SetContent(strings.Join(m.logs, "\n"))
-		m.viewport.GotoBottom()
+		m.updateLogView()
 		return m, waitForLog(m.logChannel)
 
+	case liveMsg:
+		m.liveLines = msg
+		m.updateLogView()
+		return m, waitForLive(m.liveChannel)
+
 	case engine.ProgressMsg:
 		cmd = m.progress.SetPercent(msg.CurrentPercent)
 		cmds = append(cmds, cmd)
@@ -128,11 +322,76 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, waitForProgress(m.progChannel))
 		return m, tea.Batch(cmds...)
 
+	case engine.PromptMsg:
+		if msg.Done {
+			// Answered (possibly from 'guhwizard attach'), drop it from the queue
+			for i, p := range m.prompts {
+				if p.ID == msg.Prompt.ID {
+					m.prompts = append(m.prompts[:i], m.prompts[i+1:]...)
+					if i == 0 {
+						m.promptCursor = 0
+					}
+					break
+				}
+			}
+		} else {
+			m.prompts = append(m.prompts, msg.Prompt)
+		}
+		return m, waitForPrompt(m.promptChannel)
+
 	case progress.FrameMsg:
 		progressModel, cmd := m.progress.Update(msg)
 		m.progress = progressModel.(progress.Model)
 		return m, cmd
 
+	case installedMsg:
+		// Not being able to read the local database (e.g. not on Arch) only loses the badges
+		if msg.err == nil {
+			m.markInstalled(msg.versions)
+		}
+		return m, nil
+
+	case packageCheckMsg:
+		m.checkingPackages = false
+		m.missingPackages = msg.missing
+		m.packageCheckErr = msg.err
+		return m, nil
+
+	case pacmanConfMsg:
+		m.pacmanConfDiff = msg.diff
+		m.pacmanConfErr = msg.err
+		return m, nil
+
+	case sudoMsg:
+		m.needPassword = msg.needed
+		return m, nil
+
+	case authMsg:
+		m.authenticating = false
+		if msg.err != nil {
+			m.authErr = msg.err
+			return m, nil
+		}
+		if msg.helperErr != nil {
+			// Nothing privileged runs without it, unless --without-helper asked for sudo directly
+			m.authErr = fmt.Errorf("privileged helper unavailable: %v (use --without-helper to call %s directly)", msg.helperErr, escalate.Current().Name)
+			return m, nil
+		}
+		m.needPassword = false
+		return m.startInstall()
+
+	case conflictMsg:
+		m.checkingConflicts = false
+		m.conflicts = msg.conflicts
+		m.conflictErr = msg.err
+		m.conflictCursor = 0
+		return m, nil
+
+	case upgradeMsg:
+		m.pendingUpgrade = msg.summary
+		m.upgradeErr = msg.err
+		return m, nil
+
 	case installMsg:
 		if msg.err != nil {
 			m.logs = append(m.logs, styles.Error.Render(fmt.Sprintf("\nERROR: %v", msg.err)))
@@ -185,7 +444,17 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.loadCurrentStep()
 				} else {
 					m.state = StateConfirmation
-					return m, nil
+					m.checkingPackages = true
+					m.missingPackages = nil
+					m.packageCheckErr = nil
+					m.pacmanConfDiff = ""
+					m.pacmanConfErr = nil
+					m.pendingUpgrade = nil
+					m.upgradeErr = nil
+					m.checkingConflicts = true
+					m.conflicts = nil
+					m.conflictErr = nil
+					return m, tea.Batch(checkPackages(m.cfg), planPacmanConf(m.cfg), checkUpgrade, checkConflicts(m.cfg))
 				}
 			case " ":
 				if len(m.list.Items()) > 0 {
@@ -218,15 +487,17 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case StateConfirmation:
 		if msg, ok := msg.(tea.KeyMsg); ok {
 			if msg.String() == "enter" {
-				m.state = StateInstalling
-				return m, tea.Batch(
-					waitForLog(m.logChannel),
-					waitForProgress(m.progChannel),
-					func() tea.Msg {
-						err := m.runner.Install()
-						return installMsg{err: err}
-					},
-				)
+				if m.checkingPackages || m.checkingConflicts {
+					return m, nil
+				}
+				if len(m.conflicts) > 0 {
+					m.state = StateConflicts
+					return m, nil
+				}
+				return m.startInstall()
+			} else if msg.String() == "d" || msg.String() == "D" {
+				m.deselectMissing()
+				return m, nil
 			} else if msg.String() == "esc" {
 				m.state = StateSelection
 				m.currentStepIdx = len(m.cfg.Steps) - 1
@@ -235,9 +506,70 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case StateConflicts:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "enter":
+				installer.ResolveConflicts(m.cfg, m.conflicts)
+				return m.startInstall()
+			case "esc":
+				m.state = StateConfirmation
+			default:
+				m.updateConflict(msg.String())
+			}
+		}
+		return m, nil
+
+	case StatePassword:
+		if msg, ok := msg.(tea.KeyMsg); ok && !m.authenticating {
+			switch msg.Type {
+			case tea.KeyEnter:
+				if len(m.password) == 0 {
+					return m, nil
+				}
+				password := m.password
+				m.password = nil
+				m.authenticating = true
+				m.authErr = nil
+				return m, authenticate(password)
+			case tea.KeyEsc:
+				wipe(m.password)
+				m.password = nil
+				m.state = StateConfirmation
+			case tea.KeyBackspace:
+				if len(m.password) > 0 {
+					_, size := utf8.DecodeLastRune(m.password)
+					wipe(m.password[len(m.password)-size:])
+					m.password = m.password[:len(m.password)-size]
+				}
+			case tea.KeyRunes, tea.KeySpace:
+				// Growing the slice would leave copies of the password behind,
+				// so it has a fixed capacity and longer input is refused
+				if m.password == nil {
+					m.password = make([]byte, 0, maxPasswordLen)
+				}
+				var buf [utf8.UTFMax]byte
+				for _, r := range msg.Runes {
+					n := utf8.EncodeRune(buf[:], r)
+					if len(m.password)+n > cap(m.password) {
+						m.authErr = fmt.Errorf("the password can be at most %d bytes", maxPasswordLen)
+						break
+					}
+					m.password = append(m.password, buf[:n]...)
+				}
+				wipe(buf[:])
+			}
+		}
+		return m, nil
+
 	case StateInstalling:
-		if msg, ok := msg.(tea.KeyMsg); ok && (msg.String() == "v" || msg.String() == "V") {
-			m.showLogs = !m.showLogs
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			if len(m.prompts) > 0 && m.updatePrompt(msg) {
+				return m, nil
+			}
+			if msg.String() == "v" || msg.String() == "V" {
+				m.showLogs = !m.showLogs
+			}
 		}
 		m.viewport, cmd = m.viewport.Update(msg)
 		cmds = append(cmds, cmd)
@@ -251,6 +583,139 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// updateLogView shows the log followed by the lines the running command is still redrawing.
+func (m *Model) updateLogView() {
+	lines := m.logs
+	if len(m.liveLines) > 0 {
+		lines = append(lines[:len(lines):len(lines)], m.liveLines...)
+	}
+	m.viewport.SetContent(strings.Join(lines, "\n"))
+	m.viewport.GotoBottom()
+}
+
+// updatePrompt handles the answer keys of the prompt dialog.
+// Up/down are left to the log viewport, so choices use left/right or their number.
+func (m *Model) updatePrompt(msg tea.KeyMsg) bool {
+	p := m.prompts[0]
+	choices := len(p.Choices)
+
+	switch key := msg.String(); key {
+	case "left", "h", "shift+tab":
+		if m.promptCursor > 0 {
+			m.promptCursor--
+		}
+	case "right", "l", "tab":
+		if m.promptCursor < choices-1 {
+			m.promptCursor++
+		}
+	case "enter":
+		if choices == 0 {
+			return true
+		}
+		// The Done notification removes it from the queue
+		m.runner.Answer(p.ID, p.Choices[m.promptCursor])
+	default:
+		if len(key) == 1 && key[0] >= '1' && key[0] <= '9' && int(key[0]-'1') < choices {
+			m.promptCursor = int(key[0] - '1')
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// startInstall leaves the confirmation screens and runs the installer,
+// asking for the sudo password first if there are no cached credentials.
+func (m Model) startInstall() (tea.Model, tea.Cmd) {
+	if m.needPassword {
+		m.state = StatePassword
+		m.authErr = nil
+		return m, nil
+	}
+
+	m.state = StateInstalling
+	return m, tea.Batch(
+		waitForLog(m.logChannel),
+		waitForProgress(m.progChannel),
+		waitForPrompt(m.promptChannel),
+		waitForLive(m.liveChannel),
+		func() tea.Msg {
+			err := m.runner.Install()
+			return installMsg{err: err}
+		},
+	)
+}
+
+// maxPasswordLen is the capacity of the password buffer.
+const maxPasswordLen = 256
+
+// wipe zeroes password bytes before they are dropped.
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// updateConflict moves between conflicts (up/down) and changes the choice of
+// the current one (left/right or 1-3). Base packages can't be skipped.
+func (m *Model) updateConflict(key string) {
+	c := &m.conflicts[m.conflictCursor]
+	last := installer.ConflictSkip
+	if c.Item == "" {
+		last = installer.ConflictKeep
+	}
+
+	switch key {
+	case "up", "k":
+		if m.conflictCursor > 0 {
+			m.conflictCursor--
+		}
+	case "down", "j":
+		if m.conflictCursor < len(m.conflicts)-1 {
+			m.conflictCursor++
+		}
+	case "left", "h", "shift+tab":
+		if c.Choice > installer.ConflictReplace {
+			c.Choice--
+		}
+	case "right", "l", "tab":
+		if c.Choice < last {
+			c.Choice++
+		}
+	default:
+		if len(key) == 1 && key[0] >= '1' && installer.ConflictChoice(key[0]-'1') <= last {
+			c.Choice = installer.ConflictChoice(key[0] - '1')
+		}
+	}
+}
+
+// deselectMissing unselects every item whose package could not be resolved.
+// Unresolvable base packages stay listed, they can only be fixed in the blueprint.
+func (m *Model) deselectMissing() {
+	missing := make(map[string]bool)
+	for _, name := range m.missingPackages {
+		missing[name] = true
+	}
+
+	for s := range m.cfg.Steps {
+		for i := range m.cfg.Steps[s].Items {
+			item := &m.cfg.Steps[s].Items[i]
+			if item.Selected && missing[item.Name] {
+				item.Selected = false
+				delete(missing, item.Name)
+			}
+		}
+	}
+
+	var remaining []string
+	for _, name := range m.missingPackages {
+		if missing[name] {
+			remaining = append(remaining, name)
+		}
+	}
+	m.missingPackages = remaining
+}
+
 func (m *Model) loadCurrentStep() {
 	step := m.cfg.Steps[m.currentStepIdx]
 	items := []list.Item{}
@@ -287,7 +752,11 @@ func (i listItem) Title() string {
 		}
 	}
 
-	return fmt.Sprintf("%s %s", check, displayName)
+	title := fmt.Sprintf("%s %s", check, displayName)
+	if i.configItem.Installed {
+		title += " " + styles.InstalledBadge.Render("✓ installed "+i.configItem.InstalledVersion)
+	}
+	return title
 }
 
 func (i listItem) Description() string { return i.configItem.Description }
@@ -2,6 +2,12 @@
 package ui
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"guhwizard/internal/installer"
 	"guhwizard/internal/styles"
 
 	"github.com/charmbracelet/lipgloss"
@@ -53,15 +59,44 @@ func (m Model) View() string {
 		summary += styles.Highlight.Render("Summary of Changes:") + "\n\n"
 
 		summary += "• " + m.cfg.Settings.AURHelper + " (AUR Helper)\n"
+		if note := installer.AURHelperOverride(m.cfg); note != "" {
+			summary += styles.Error.Render("  "+note) + "\n"
+		}
 
+		var toInstall, present, system string
 		for _, step := range m.cfg.Steps {
 			for _, item := range step.Items {
-				if item.Selected {
-					summary += "• " + item.Name + "\n"
+				if !item.Selected {
+					continue
+				}
+				if step.System {
+					system += "• " + item.Name + styles.Subtle.Render(" ("+step.ID+")") + "\n"
+				} else if item.Installed {
+					present += "• " + item.Name + styles.Subtle.Render(" ("+item.InstalledVersion+")") + "\n"
+				} else {
+					toInstall += "• " + item.Name + "\n"
 				}
 			}
 		}
 
+		if toInstall != "" {
+			summary += "\n" + styles.Highlight.Render("Will install:") + "\n" + toInstall
+		}
+		if present != "" {
+			summary += "\n" + styles.Highlight.Render("Already present:") + "\n" + present
+		}
+		if system != "" {
+			summary += "\n" + styles.Highlight.Render("System settings:") + "\n" + system
+		}
+
+		summary += m.upgradeView()
+
+		summary += m.pacmanConfView()
+
+		summary += m.packageCheckView()
+
+		summary += m.conflictNoteView()
+
 		summary += "\n" + styles.Subtle.Render("Press [Enter] to Confirm or [Ctrl+C] to Cancel")
 
 		content = lipgloss.JoinVertical(lipgloss.Center,
@@ -69,6 +104,18 @@ func (m Model) View() string {
 			summary,
 		)
 
+	case StateConflicts:
+		content = lipgloss.JoinVertical(lipgloss.Center,
+			header,
+			m.conflictsView(),
+		)
+
+	case StatePassword:
+		content = lipgloss.JoinVertical(lipgloss.Center,
+			header,
+			m.passwordView(),
+		)
+
 	case StateInstalling:
 		var mainArea string
 		if m.showLogs {
@@ -87,6 +134,9 @@ func (m Model) View() string {
 				styles.Subtle.Render("(Press 'V' to view verbose logs)"),
 			)
 		}
+		if len(m.prompts) > 0 {
+			mainArea = lipgloss.JoinVertical(lipgloss.Center, mainArea, "\n", m.promptView())
+		}
 		content = lipgloss.JoinVertical(lipgloss.Center, header, mainArea)
 
 	case StateDone:
@@ -103,3 +153,178 @@ func (m Model) View() string {
 		styles.Container.Render(content),
 	)
 }
+
+// packageCheckView reports package names that don't exist in the repos or the AUR.
+func (m Model) packageCheckView() string {
+	switch {
+	case m.checkingPackages:
+		return "\n" + styles.Subtle.Render("Checking package names...") + "\n"
+	case m.packageCheckErr != nil:
+		return "\n" + styles.Subtle.Render(fmt.Sprintf("Could not verify package names: %v", m.packageCheckErr)) + "\n"
+	case len(m.missingPackages) == 0:
+		return ""
+	}
+
+	base := make(map[string]bool)
+	for _, name := range m.cfg.Settings.BasePackages {
+		base[name] = true
+	}
+
+	out := "\n" + styles.Error.Render("Not found in the repos or the AUR:") + "\n"
+	for _, name := range m.missingPackages {
+		line := "• " + name
+		if base[name] {
+			line += styles.Subtle.Render(" (base package, fix it in the blueprint)")
+		}
+		out += line + "\n"
+	}
+	out += styles.Subtle.Render("Press [D] to deselect them or [Esc] to go back") + "\n"
+	return out
+}
+
+// passwordView is the masked sudo password prompt.
+func (m Model) passwordView() string {
+	out := styles.Highlight.Render("Administrator password required") + "\n\n"
+	out += styles.Subtle.Render("sudo needs your password to install packages and change system files.") + "\n"
+	out += styles.Subtle.Render("It is passed to sudo and not kept.") + "\n\n"
+	out += "Password: " + strings.Repeat("•", utf8.RuneCount(m.password)) + "\n\n"
+
+	switch {
+	case m.authenticating:
+		out += styles.Subtle.Render("Checking...") + "\n"
+	case errors.Is(m.authErr, installer.ErrWrongPassword):
+		out += styles.Error.Render("Wrong password, try again.") + "\n"
+	case m.authErr != nil:
+		out += styles.Error.Render(m.authErr.Error()) + "\n"
+	}
+	out += styles.Subtle.Render("[Enter] continue, [Esc] back")
+	return out
+}
+
+// conflictNoteView announces the conflict screen that follows the confirmation.
+func (m Model) conflictNoteView() string {
+	switch {
+	case m.checkingConflicts:
+		return "\n" + styles.Subtle.Render("Checking for conflicts with installed packages...") + "\n"
+	case m.conflictErr != nil:
+		return "\n" + styles.Subtle.Render(fmt.Sprintf("Could not check for conflicts: %v", m.conflictErr)) + "\n"
+	case len(m.conflicts) == 0:
+		return ""
+	}
+	return "\n" + styles.Error.Render(fmt.Sprintf("%d conflicts with installed packages, resolve them on the next screen", len(m.conflicts))) + "\n"
+}
+
+// conflictsView lists every conflict with its resolution choices.
+func (m Model) conflictsView() string {
+	out := styles.Highlight.Render("Conflicts with installed packages:") + "\n\n"
+	for i, c := range m.conflicts {
+		verb := "conflicts with"
+		if c.Replaces {
+			verb = "replaces"
+		}
+		line := fmt.Sprintf("%s %s %s", c.Package, verb, c.Installed)
+		if c.Item == "" {
+			line += styles.Subtle.Render(" (base package)")
+		}
+
+		cursor := "  "
+		if i == m.conflictCursor {
+			cursor = styles.Highlight.Render("> ")
+		}
+
+		var choices []string
+		for j, label := range installer.ConflictChoices {
+			if installer.ConflictChoice(j) == installer.ConflictSkip && c.Item == "" {
+				continue
+			}
+			label = fmt.Sprintf(" %d. %s ", j+1, label)
+			if installer.ConflictChoice(j) == c.Choice {
+				choices = append(choices, styles.ItemSelectedTitle.Render(label))
+			} else {
+				choices = append(choices, styles.ItemNormalTitle.Render(label))
+			}
+		}
+
+		out += cursor + line + "\n  " + lipgloss.JoinHorizontal(lipgloss.Top, choices...) + "\n\n"
+	}
+	out += styles.Subtle.Render("[↑/↓] conflict, [←/→] choose, [Enter] install, [Esc] back")
+	return out
+}
+
+// promptView renders the first pending installer question with its choices.
+func (m Model) promptView() string {
+	p := m.prompts[0]
+
+	var choices []string
+	for i, c := range p.Choices {
+		label := fmt.Sprintf(" %d. %s ", i+1, c)
+		if i == m.promptCursor {
+			choices = append(choices, styles.ItemSelectedTitle.Render(label))
+		} else {
+			choices = append(choices, styles.ItemNormalTitle.Render(label))
+		}
+	}
+
+	return styles.Container.Render(lipgloss.JoinVertical(lipgloss.Left,
+		styles.Highlight.Render(p.Question),
+		"",
+		lipgloss.JoinHorizontal(lipgloss.Top, choices...),
+		"",
+		styles.Subtle.Render("[←/→] choose, [Enter] answer"),
+	))
+}
+
+// upgradeView shows the size of the system upgrade and warns about stale sync databases.
+func (m Model) upgradeView() string {
+	if m.upgradeErr != nil {
+		return "\n" + styles.Subtle.Render(fmt.Sprintf("Could not check for system upgrades: %v", m.upgradeErr)) + "\n"
+	}
+	u := m.pendingUpgrade
+	if u == nil {
+		return ""
+	}
+
+	out := "\n" + styles.Highlight.Render("System upgrade:") + "\n"
+	switch {
+	case m.cfg.Settings.SkipSystemUpgrade:
+		out += "• " + styles.Subtle.Render("skipped (skip_system_upgrade)") + "\n"
+	case u.Packages == 0:
+		out += "• up to date\n"
+	default:
+		out += fmt.Sprintf("• %d packages, %s to download\n", u.Packages, installer.FormatSize(u.DownloadSize))
+	}
+
+	if u.Stale {
+		msg := fmt.Sprintf("Sync databases are %s old.", installer.FormatAge(u.SyncAge))
+		if m.cfg.Settings.SkipSystemUpgrade {
+			msg += " Without the upgrade, downloads may fail or mismatch installed libraries."
+		} else {
+			msg += " They are refreshed first, the upgrade may be larger than shown."
+		}
+		out += styles.Error.Render(msg) + "\n"
+	}
+	return out
+}
+
+// pacmanConfView shows the pacman.conf edits as a diff.
+func (m Model) pacmanConfView() string {
+	if m.pacmanConfErr != nil {
+		return "\n" + styles.Subtle.Render(fmt.Sprintf("Could not preview pacman.conf changes: %v", m.pacmanConfErr)) + "\n"
+	}
+	if m.pacmanConfDiff == "" {
+		return ""
+	}
+
+	out := "\n" + styles.Highlight.Render("pacman.conf changes:") + "\n"
+	for _, line := range strings.Split(strings.TrimRight(m.pacmanConfDiff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			out += styles.Success.Render(line) + "\n"
+		case strings.HasPrefix(line, "-"):
+			out += styles.Error.Render(line) + "\n"
+		default:
+			out += styles.Subtle.Render(line) + "\n"
+		}
+	}
+	return out
+}